@@ -27,8 +27,90 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/enrichment/cache": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Статистика кэша ответов внешних сервисов обогащения.",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/enricher.CacheStats"
+                        }
+                    },
+                    "404": {
+                        "description": "Кэширование выключено"
+                    }
+                }
+            }
+        },
+        "/enrichment/conflicts": {
+            "get": {
+                "description": "Возвращает от новых к старым случаи, когда источники предложили для поля разные значения,\nвместе с выбранным значением и стратегией выбора.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Расхождения источников обогащения.",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "offset",
+                        "name": "offset",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "limit",
+                        "name": "limit",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Поле, например gender",
+                        "name": "field",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.EnrichmentConflict"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/enrichment/providers": {
+            "get": {
+                "description": "Для каждого провайдера возвращает состояние автомата защиты (closed, open, half_open),\nпричину отключения и остаток суточной квоты.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Состояние внешних сервисов обогащения.",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/enricher.BreakerStatus"
+                            }
+                        }
+                    }
+                }
+            }
+        },
         "/users/delete/{id}": {
             "delete": {
+                "description": "Пользователь помечается удалённым и перестаёт выбираться, но его можно восстановить\nзапросом /users/{id}/restore, пока он не очищен окончательно по истечении срока хранения.",
                 "summary": "Удаление пользователя по id.",
                 "parameters": [
                     {
@@ -48,6 +130,7 @@ const docTemplate = `{
         },
         "/users/get": {
             "post": {
+                "description": "Фильтр - условие {\"field\": \"age\", \"op\": \"gte\", \"value\": 25}, группа {\"and\": [...]} или {\"or\": [...]},\nотрицание {\"not\": {...}} либо отбор по странам-кандидатам {\"candidate\": {\"countries\": [\"RU\"], \"min_probability\": 0.3}}.\nОператоры: eq (по умолчанию), ne, gt, gte, lt, lte, in, not_in (value - список), prefix, contains, ilike (для строк)\nи is_null (value - true или false). Например, возраст от 25 до 40 и фамилия на «Ив»:\n{\"and\": [{\"field\": \"age\", \"op\": \"gte\", \"value\": 25}, {\"field\": \"age\", \"op\": \"lte\", \"value\": 40}, {\"field\": \"surname\", \"op\": \"prefix\", \"value\": \"Ив\"}]}\nПоддерживается и прежний формат {\"поле\": [значения]}: значения поля объединяются через OR, поля - через AND,\nключи min_\u003cполе\u003e задают минимальную уверенность сервиса (например min_gender_probability), а nationality_candidate и min_candidate_probability отбирают по странам-кандидатам.\nУдалённые пользователи не выбираются, если не задан параметр include_deleted=true.\nПользователи сначала фильтруются, затем упорядочиваются по sort и id и делятся на страницы.\nЕсли есть следующая страница, её курсор возвращается в заголовке X-Next-Cursor;\nзапрос с параметром cursor вместо offset продолжает выборку с места, где закончилась предыдущая страница.\nОбщее число подходящих под фильтр пользователей возвращается в заголовке X-Total-Count. С параметром envelope=true\nответ - объект usersPage с пользователями, их общим числом и курсором следующей страницы,\nа с include=facets в него добавляется число пользователей по полу, национальности и диапазонам возраста.",
                 "produces": [
                     "application/json"
                 ],
@@ -55,10 +138,9 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "offset",
+                        "description": "offset; не нужен, если задан cursor",
                         "name": "offset",
-                        "in": "query",
-                        "required": true
+                        "in": "query"
                     },
                     {
                         "type": "integer",
@@ -67,29 +149,72 @@ const docTemplate = `{
                         "in": "query",
                         "required": true
                     },
+                    {
+                        "type": "string",
+                        "description": "курсор следующей страницы из заголовка X-Next-Cursor",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Сортировка: поля через запятую в формате поле[:asc|desc][:nulls_first|nulls_last], например nationality,age:desc",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "true - вернуть объект usersPage вместо массива пользователей",
+                        "name": "envelope",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "true - выбирать и удалённых пользователей",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Через запятую: provenance - добавить происхождение значений полей, facets - добавить фасеты в usersPage",
+                        "name": "include",
+                        "in": "query"
+                    },
                     {
                         "description": "filter",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "array",
-                                "items": {}
-                            }
+                            "$ref": "#/definitions/model.Filter"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK"
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/controller.usersPage"
+                        },
+                        "headers": {
+                            "X-Next-Cursor": {
+                                "type": "string",
+                                "description": "курсор следующей страницы, если она есть"
+                            },
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "число пользователей, подходящих под фильтр"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неизвестное поле или оператор фильтра либо значение неподходящего типа"
                     }
                 }
             }
         },
         "/users/new": {
             "post": {
+                "description": "Ответ содержит итоги обогащения по полям: ok, no_data (сервис не знает значения),\nprovider_error (сервис ответил ошибкой) или skipped (к сервису не обращались).\nВ асинхронном режиме пользователь сохраняется без обогащения, ответ имеет код 202,\nа данные из внешних сервисов появляются позже.",
                 "consumes": [
                     "application/json"
                 ],
@@ -98,6 +223,18 @@ const docTemplate = `{
                 ],
                 "summary": "Создание нового пользователя в базе данных.",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Обогатители через запятую, например age,gender. По умолчанию используются все",
+                        "name": "enrich",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "provenance - добавить происхождение значений полей",
+                        "name": "include",
+                        "in": "query"
+                    },
                     {
                         "description": "Request",
                         "name": "request",
@@ -108,18 +245,107 @@ const docTemplate = `{
                         }
                     }
                 ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/controller.userResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/controller.userResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/search": {
+            "get": {
+                "description": "Находит пользователей, ФИО которых похоже на запрос, и возвращает их по убыванию сходства.\nКириллица и латиница взаимозаменяемы (Иванова и Ivanova), опечатки допускаются,\nа последнее слово может быть началом слова: «Иванова Мар». Найденные слова в полях name, surname\nи patronymic выделяются тегом \u003cmark\u003e в highlight.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Поиск пользователей по ФИО.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Запрос, например Иванова Мар",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Сколько пользователей вернуть: по умолчанию 20, не больше 100",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.User"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.SearchResult"
+                            }
                         }
+                    },
+                    "400": {
+                        "description": "Пустой запрос или неверный limit"
+                    }
+                }
+            }
+        },
+        "/users/suggest": {
+            "get": {
+                "description": "Возвращает различные значения поля, начинающиеся с prefix без учёта регистра, и число пользователей\nс каждым из них, начиная с самых частых. Подсказки кэшируются на короткое время. Кэш сбрасывается,\nкогда этот экземпляр сервиса добавляет, изменяет, удаляет или восстанавливает пользователей,\nа изменения через другие экземпляры могут появиться в подсказках не сразу.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Подсказки при вводе имени, фамилии или отчества.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "name, surname (по умолчанию) или patronymic",
+                        "name": "field",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Начало значения, например Ив",
+                        "name": "prefix",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Сколько подсказок вернуть: по умолчанию 10, не больше 50",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Suggestion"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неизвестное поле, пустой prefix или неверный limit"
                     }
                 }
             }
         },
         "/users/upd/{id}": {
             "patch": {
+                "description": "Обновлённые поля блокируются: обогатители не перезаписывают их до разблокировки.",
                 "consumes": [
                     "application/json"
                 ],
@@ -132,6 +358,12 @@ const docTemplate = `{
                         "in": "path",
                         "required": true
                     },
+                    {
+                        "type": "string",
+                        "description": "Источник значений: operator (по умолчанию) или import",
+                        "name": "source",
+                        "in": "query"
+                    },
                     {
                         "description": "Request",
                         "name": "request",
@@ -145,6 +377,143 @@ const docTemplate = `{
                 "responses": {
                     "200": {
                         "description": "OK"
+                    },
+                    "400": {
+                        "description": "Поле нельзя изменить или значение неподходящего типа"
+                    },
+                    "404": {
+                        "description": "Пользователь не найден или удалён"
+                    }
+                }
+            }
+        },
+        "/users/{id}/enrich": {
+            "post": {
+                "description": "Перезаписывает поля, для которых внешние сервисы вернули данные; остальные поля не меняются.\nЗначения, заданные оператором или импортом, не перезаписываются, их поля получают статус skipped.\nВ асинхронном режиме ставит задание в очередь и отвечает 202.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Повторное обогащение пользователя по id.",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Обогатители через запятую, например age,gender. По умолчанию используются все",
+                        "name": "enrich",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "provenance - добавить происхождение значений полей",
+                        "name": "include",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/controller.userResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/controller.userResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    }
+                }
+            }
+        },
+        "/users/{id}/locks/{field}": {
+            "delete": {
+                "description": "После разблокировки обогатители снова могут перезаписывать значение поля.",
+                "summary": "Разблокирует поле пользователя.",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Поле, например age",
+                        "name": "field",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    }
+                }
+            }
+        },
+        "/users/{id}/nationalities": {
+            "get": {
+                "description": "Возвращает все страны, которые внешний сервис предложил для пользователя, по убыванию вероятности.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Страны-кандидаты пользователя.",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Nationality"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    }
+                }
+            }
+        },
+        "/users/{id}/restore": {
+            "post": {
+                "summary": "Восстановление удалённого пользователя по id.",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Пользователь не найден, не удалён или уже очищен"
                     }
                 }
             }
@@ -157,6 +526,10 @@ const docTemplate = `{
                 "name": {
                     "type": "string"
                 },
+                "nationality": {
+                    "description": "Nationality - код страны пользователя, если он известен. Тогда национальность не определяется\nвнешним сервисом, а возраст и пол уточняются по этой стране.",
+                    "type": "string"
+                },
                 "patronymic": {
                     "type": "string"
                 },
@@ -165,27 +538,519 @@ const docTemplate = `{
                 }
             }
         },
+        "controller.userResponse": {
+            "type": "object",
+            "properties": {
+                "age": {
+                    "type": "integer"
+                },
+                "age_count": {
+                    "description": "Уверенность внешних сервисов в определённых ими значениях.\nCount - количество записей с таким именем, на которых основан ответ сервиса.",
+                    "type": "integer"
+                },
+                "conflicts": {
+                    "description": "Conflicts - расхождения источников, найденные при последнем обогащении.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.EnrichmentConflict"
+                    }
+                },
+                "deleted_at": {
+                    "description": "DeletedAt - время удаления. nil, если пользователь не удалён. Удалённого пользователя можно восстановить,\nпока он не очищен окончательно.",
+                    "type": "string"
+                },
+                "enriched_at": {
+                    "description": "EnrichedAt - время последнего обогащения. nil, если пользователь ещё не обогащался.",
+                    "type": "string"
+                },
+                "enrichment": {
+                    "description": "Enrichment - итоги обогащения по полям. Отсутствует, если обогащение не выполнялось или отложено.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/enricher.Report"
+                        }
+                    ]
+                },
+                "gender": {
+                    "type": "string"
+                },
+                "gender_count": {
+                    "type": "integer"
+                },
+                "gender_probability": {
+                    "type": "number"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "nationalities": {
+                    "description": "Nationalities - все страны, к которым может относиться пользователь, по убыванию вероятности.\nЗаполняется при определении национальности и только по запросу при чтении.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Nationality"
+                    }
+                },
+                "nationality": {
+                    "type": "string"
+                },
+                "nationality_count": {
+                    "type": "integer"
+                },
+                "nationality_probability": {
+                    "type": "number"
+                },
+                "patronymic": {
+                    "type": "string"
+                },
+                "provenance": {
+                    "description": "Provenance - происхождение значений полей по их именам. Заполняется только по запросу.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/model.Provenance"
+                    }
+                },
+                "surname": {
+                    "type": "string"
+                }
+            }
+        },
+        "controller.usersPage": {
+            "type": "object",
+            "properties": {
+                "facets": {
+                    "description": "Facets - число подходящих под фильтр пользователей по значениям полей. Есть только при include=facets.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.Facets"
+                        }
+                    ]
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.User"
+                    }
+                },
+                "next_cursor": {
+                    "description": "NextCursor - курсор следующей страницы, если она есть.",
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "enricher.BreakerStatus": {
+            "type": "object",
+            "properties": {
+                "failures": {
+                    "type": "integer"
+                },
+                "open_until": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "quota": {
+                    "$ref": "#/definitions/enricher.Quota"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                }
+            }
+        },
+        "enricher.CacheStats": {
+            "type": "object",
+            "properties": {
+                "hits": {
+                    "type": "integer"
+                },
+                "misses": {
+                    "type": "integer"
+                },
+                "negative_hits": {
+                    "description": "попадания в записи об отсутствии данных, входят в Hits",
+                    "type": "integer"
+                },
+                "size": {
+                    "description": "количество записей в памяти",
+                    "type": "integer"
+                },
+                "store_hits": {
+                    "description": "попадания в постоянное хранилище, входят в Hits",
+                    "type": "integer"
+                }
+            }
+        },
+        "enricher.Outcome": {
+            "type": "object",
+            "properties": {
+                "provider": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "ok",
+                        "no_data",
+                        "provider_error",
+                        "skipped"
+                    ]
+                }
+            }
+        },
+        "enricher.Quota": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer"
+                },
+                "remaining": {
+                    "type": "integer"
+                },
+                "reset_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "enricher.Report": {
+            "type": "object",
+            "additionalProperties": {
+                "$ref": "#/definitions/enricher.Outcome"
+            }
+        },
+        "model.Candidate": {
+            "type": "object",
+            "properties": {
+                "probability": {
+                    "type": "number"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.CandidateFilter": {
+            "type": "object",
+            "properties": {
+                "countries": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "min_probability": {
+                    "type": "number"
+                }
+            }
+        },
+        "model.EnrichmentConflict": {
+            "type": "object",
+            "properties": {
+                "candidates": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Candidate"
+                    }
+                },
+                "chosen": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "field": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "strategy": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.Facets": {
+            "type": "object",
+            "properties": {
+                "age": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "gender": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "nationality": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "model.Filter": {
+            "type": "object",
+            "properties": {
+                "and": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Filter"
+                    }
+                },
+                "candidate": {
+                    "$ref": "#/definitions/model.CandidateFilter"
+                },
+                "field": {
+                    "type": "string"
+                },
+                "not": {
+                    "$ref": "#/definitions/model.Filter"
+                },
+                "op": {
+                    "type": "string",
+                    "enum": [
+                        "eq",
+                        "ne",
+                        "gt",
+                        "gte",
+                        "lt",
+                        "lte",
+                        "in",
+                        "not_in",
+                        "prefix",
+                        "contains",
+                        "ilike",
+                        "is_null"
+                    ]
+                },
+                "or": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Filter"
+                    }
+                },
+                "value": {}
+            }
+        },
+        "model.Nationality": {
+            "type": "object",
+            "properties": {
+                "country_id": {
+                    "type": "string"
+                },
+                "probability": {
+                    "type": "number"
+                }
+            }
+        },
+        "model.Provenance": {
+            "type": "object",
+            "properties": {
+                "locked": {
+                    "description": "Locked запрещает обогатителям перезаписывать значение. Выставляется для значений,\nзаданных вручную, и снимается явной разблокировкой поля.",
+                    "type": "boolean"
+                },
+                "provider": {
+                    "description": "внешний сервис, если Source равен SourceEnricher",
+                    "type": "string"
+                },
+                "raw": {
+                    "description": "начало ответа внешнего сервиса",
+                    "type": "string"
+                },
+                "source": {
+                    "type": "string",
+                    "enum": [
+                        "enricher",
+                        "operator",
+                        "import"
+                    ]
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.SearchResult": {
+            "type": "object",
+            "properties": {
+                "age": {
+                    "type": "integer"
+                },
+                "age_count": {
+                    "description": "Уверенность внешних сервисов в определённых ими значениях.\nCount - количество записей с таким именем, на которых основан ответ сервиса.",
+                    "type": "integer"
+                },
+                "conflicts": {
+                    "description": "Conflicts - расхождения источников, найденные при последнем обогащении.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.EnrichmentConflict"
+                    }
+                },
+                "deleted_at": {
+                    "description": "DeletedAt - время удаления. nil, если пользователь не удалён. Удалённого пользователя можно восстановить,\nпока он не очищен окончательно.",
+                    "type": "string"
+                },
+                "enriched_at": {
+                    "description": "EnrichedAt - время последнего обогащения. nil, если пользователь ещё не обогащался.",
+                    "type": "string"
+                },
+                "gender": {
+                    "type": "string"
+                },
+                "gender_count": {
+                    "type": "integer"
+                },
+                "gender_probability": {
+                    "type": "number"
+                },
+                "highlight": {
+                    "description": "Highlight - поля, в которых нашлись слова запроса, по их именам. Найденные слова обёрнуты в \u003cmark\u003e,\nостальной текст экранирован для вставки в HTML.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "nationalities": {
+                    "description": "Nationalities - все страны, к которым может относиться пользователь, по убыванию вероятности.\nЗаполняется при определении национальности и только по запросу при чтении.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Nationality"
+                    }
+                },
+                "nationality": {
+                    "type": "string"
+                },
+                "nationality_count": {
+                    "type": "integer"
+                },
+                "nationality_probability": {
+                    "type": "number"
+                },
+                "patronymic": {
+                    "type": "string"
+                },
+                "provenance": {
+                    "description": "Provenance - происхождение значений полей по их именам. Заполняется только по запросу.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/model.Provenance"
+                    }
+                },
+                "score": {
+                    "description": "Score - насколько ФИО пользователя похоже на запрос: от 0 до 1.",
+                    "type": "number"
+                },
+                "surname": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.Suggestion": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
         "model.User": {
             "type": "object",
             "properties": {
                 "age": {
                     "type": "integer"
                 },
+                "age_count": {
+                    "description": "Уверенность внешних сервисов в определённых ими значениях.\nCount - количество записей с таким именем, на которых основан ответ сервиса.",
+                    "type": "integer"
+                },
+                "conflicts": {
+                    "description": "Conflicts - расхождения источников, найденные при последнем обогащении.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.EnrichmentConflict"
+                    }
+                },
+                "deleted_at": {
+                    "description": "DeletedAt - время удаления. nil, если пользователь не удалён. Удалённого пользователя можно восстановить,\nпока он не очищен окончательно.",
+                    "type": "string"
+                },
+                "enriched_at": {
+                    "description": "EnrichedAt - время последнего обогащения. nil, если пользователь ещё не обогащался.",
+                    "type": "string"
+                },
                 "gender": {
                     "type": "string"
                 },
+                "gender_count": {
+                    "type": "integer"
+                },
+                "gender_probability": {
+                    "type": "number"
+                },
                 "id": {
                     "type": "integer"
                 },
                 "name": {
                     "type": "string"
                 },
+                "nationalities": {
+                    "description": "Nationalities - все страны, к которым может относиться пользователь, по убыванию вероятности.\nЗаполняется при определении национальности и только по запросу при чтении.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Nationality"
+                    }
+                },
                 "nationality": {
                     "type": "string"
                 },
+                "nationality_count": {
+                    "type": "integer"
+                },
+                "nationality_probability": {
+                    "type": "number"
+                },
                 "patronymic": {
                     "type": "string"
                 },
+                "provenance": {
+                    "description": "Provenance - происхождение значений полей по их именам. Заполняется только по запросу.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/model.Provenance"
+                    }
+                },
                 "surname": {
                     "type": "string"
                 }
@@ -3,20 +3,34 @@ package app
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/aachex/service/docs"
 	"github.com/aachex/service/internal/controller"
+	"github.com/aachex/service/internal/enricher"
+	"github.com/aachex/service/internal/enricher/fake"
 	"github.com/aachex/service/internal/repository/postgres"
+	"github.com/aachex/service/internal/worker"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
 type App struct {
-	srv    *http.Server
-	db     *sql.DB
-	logger *slog.Logger
+	srv            *http.Server
+	db             *sql.DB
+	enrichWorkers  *worker.EnrichmentPool
+	refreshSweeper *worker.RefreshSweeper
+	purgeSweeper   *worker.PurgeSweeper
+	fakeProviders  *http.Server
+	plugins        []*enricher.ProcessEnricher
+	logger         *slog.Logger
 }
 
 func New(l *slog.Logger) *App {
@@ -41,6 +55,73 @@ func (app *App) Start() {
 
 	// Репозитории
 	users := postgres.NewUsersRepository(app.db)
+	jobs := postgres.NewJobsRepository(app.db)
+
+	// Кэш ответов внешних сервисов
+	cache, err := newEnrichmentCache(app.db)
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+
+	providers, err := providersConfig()
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+
+	// Режим разработки: вместо внешних сервисов используются фейковые
+	if os.Getenv("ENRICH_FAKE") == "true" {
+		if err = app.startFakeProviders(&providers); err != nil {
+			app.logger.Error(err.Error())
+			return
+		}
+	}
+
+	client, err := newProvidersClient()
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+
+	breakerCfg, err := breakerConfig(postgres.NewProviderQuotasRepository(app.db))
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+
+	providerOpts := []enricher.ProviderOption{
+		enricher.WithHTTPClient(client),
+		enricher.WithBreaker(breakerCfg),
+	}
+	var cacheStats interface{ Stats() enricher.CacheStats }
+	if cache != nil {
+		providerOpts = append(providerOpts, enricher.WithCache(cache))
+		cacheStats = cache
+	}
+
+	// Обогатители
+	builtin := enricher.Builtin(providers, providerOpts...)
+	app.plugins, err = processEnrichers(os.Getenv("ENRICH_PLUGINS"))
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+	plugins := make([]enricher.Enricher, len(app.plugins))
+	for i, p := range app.plugins {
+		plugins[i] = p
+	}
+	available, err := withDictionaries(builtin, plugins, os.Getenv("ENRICH_DICTIONARY"), providers)
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+	enrichers, err := newEnricherRegistry(os.Getenv("ENRICHERS"), available)
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+	app.logger.Info("enrichers configured", slog.Any("enrichers", enrichers.Names()))
 
 	// Обаботчики
 	mux := http.NewServeMux()
@@ -49,9 +130,72 @@ func (app *App) Start() {
 	})
 	mux.HandleFunc("/swagger/", httpSwagger.Handler(httpSwagger.URL("/spec")))
 
-	usersController := controller.NewUsersController(users, app.logger)
+	usersController := controller.NewUsersController(users, enrichers, app.logger)
+	// Ключ подписи курсоров страниц. Без него курсоры не переживают перезапуск и не подходят другим экземплярам сервиса
+	if secret := os.Getenv("CURSOR_SECRET"); secret != "" {
+		usersController.SetCursorKey([]byte(secret))
+	} else {
+		app.logger.Warn("CURSOR_SECRET is not set, page cursors are signed with a random key")
+	}
+	suggestTTL, err := durationEnv("SUGGEST_CACHE_TTL", 30*time.Second)
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+	usersController.SetSuggestCacheTTL(suggestTTL)
 	usersController.RegisterHandlers(mux)
 
+	enrichmentController := controller.NewEnrichmentController(
+		cacheStats, enricher.Breakers(builtin), postgres.NewConflictsRepository(app.db), app.logger)
+	enrichmentController.RegisterHandlers(mux)
+
+	refreshCfg, err := refreshConfig()
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+	async := os.Getenv("ENRICH_ASYNC") == "true"
+
+	// Асинхронное обогащение. Обработчики нужны и для фонового повторного обогащения.
+	if async || refreshCfg.Interval > 0 {
+		cfg, err := enrichWorkersConfig()
+		if err != nil {
+			app.logger.Error(err.Error())
+			return
+		}
+
+		app.enrichWorkers = worker.NewEnrichmentPool(jobs, users, enrichers, app.logger, cfg)
+		app.enrichWorkers.Start()
+		app.logger.Info("enrichment workers started", slog.Int("workers", cfg.Workers))
+	}
+	if async {
+		usersController.EnableAsyncEnrichment()
+		app.logger.Info("async enrichment enabled")
+	}
+
+	// Фоновое повторное обогащение
+	if refreshCfg.Interval > 0 {
+		app.refreshSweeper = worker.NewRefreshSweeper(users, enricher.Breakers(builtin), app.logger, refreshCfg)
+		app.refreshSweeper.Start()
+		app.logger.Info("background refresh enabled",
+			slog.Duration("interval", refreshCfg.Interval),
+			slog.Int("batch", refreshCfg.Batch))
+	}
+
+	// Очистка удалённых пользователей
+	purgeCfg, err := purgeConfig()
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+	if purgeCfg.Interval > 0 {
+		app.purgeSweeper = worker.NewPurgeSweeper(users, app.logger, purgeCfg)
+		app.purgeSweeper.Start()
+		app.logger.Info("purge of deleted users enabled",
+			slog.Duration("interval", purgeCfg.Interval),
+			slog.Duration("retention", purgeCfg.Retention))
+	}
+
 	// Старт сервера
 	app.srv = &http.Server{
 		Addr:    ":" + os.Getenv("PORT"),
@@ -61,12 +205,497 @@ func (app *App) Start() {
 	app.srv.ListenAndServe()
 }
 
+// startFakeProviders запускает фейковые agify, genderize и nationalize на адресе ENRICH_FAKE_ADDR
+// и направляет к ним обогатители из cfg. Таблица ответов читается из файла ENRICH_FAKE_TABLE,
+// если он задан, иначе используется встроенная. Сбои включаются запросами PUT /faults/{сервис}.
+func (app *App) startFakeProviders(cfg *enricher.ProvidersConfig) error {
+	table := fake.DefaultTable()
+	if path := os.Getenv("ENRICH_FAKE_TABLE"); path != "" {
+		var err error
+		if table, err = fake.LoadTable(path); err != nil {
+			return err
+		}
+	}
+
+	addr := os.Getenv("ENRICH_FAKE_ADDR")
+	if addr == "" {
+		addr = "127.0.0.1:8090"
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	app.fakeProviders = &http.Server{Handler: fake.NewHandler(table)}
+	go app.fakeProviders.Serve(ln)
+
+	base := "http://" + ln.Addr().String()
+	cfg.Agify.BaseURL = base + fake.PathAgify
+	cfg.Genderize.BaseURL = base + fake.PathGenderize
+	cfg.Nationalize.BaseURL = base + fake.PathNationalize
+
+	app.logger.Warn("fake enrichment providers enabled", slog.String("addr", base))
+	return nil
+}
+
+// withDictionaries сочетает обогатители пола и национальности с определением этих полей по встроенным словарям.
+// mode задаёт порядок: fallback (по умолчанию) - словари только когда внешний сервис не ответил или не знает ответа;
+// first - сначала словари, а внешние сервисы только для неизвестных им пользователей; off - словари не используются.
+// Предположения словарей отбрасываются по тем же порогам уверенности, что и ответы внешних сервисов из providers.
+//
+// Если для поля задана стратегия ENRICH_STRATEGY_<ПОЛЕ> (priority, confidence или vote), опрашиваются оба источника
+// в том же порядке, а значение выбирается по стратегии. Веса источников для vote задаются ENRICH_WEIGHT_<ИСТОЧНИК>.
+// Стратегия для поля, у которого нет второго источника, считается ошибкой конфигурации.
+//
+// Программы plugins, которые заполняют поле встроенного обогатителя, становятся его последними источниками
+// и отдельно не регистрируются. Для такого поля стратегия обязательна: иначе источники работали бы одновременно,
+// и в поле оставалось бы значение, полученное последним. Остальные программы добавляются в конец как есть.
+func withDictionaries(available, plugins []enricher.Enricher, mode string, providers enricher.ProvidersConfig) ([]enricher.Enricher, error) {
+	dictionaries := map[string]enricher.Enricher{
+		"gender":      enricher.NewDictionaryGenderEnricher(providers.Genderize.MinProbability),
+		"nationality": enricher.NewDictionaryNationalityEnricher(providers.Nationalize.MinProbability),
+	}
+	switch mode {
+	case "", "fallback", "first", "off":
+	default:
+		return nil, fmt.Errorf("ENRICH_DICTIONARY: unknown mode %q", mode)
+	}
+
+	combined := make([]enricher.Enricher, 0, len(available)+len(plugins))
+	merged := make(map[enricher.Enricher]bool)
+	for _, e := range available {
+		d, ok := dictionaries[e.Name()]
+		strategyEnv := "ENRICH_STRATEGY_" + strings.ToUpper(e.Name())
+		strategy := os.Getenv(strategyEnv)
+
+		sources := []enricher.Enricher{e}
+		switch {
+		case !ok || mode == "off":
+		case mode == "first":
+			sources = []enricher.Enricher{d, e}
+		default:
+			sources = []enricher.Enricher{e, d}
+		}
+
+		var overlapping []enricher.Enricher
+		for _, p := range plugins {
+			if _, ok := enricher.Overlap(p, e); ok {
+				overlapping = append(overlapping, p)
+				merged[p] = true
+			}
+		}
+		sources = append(sources, overlapping...)
+
+		switch {
+		case len(sources) == 1 && strategy != "":
+			return nil, fmt.Errorf("%s: field %q has a single source", strategyEnv, e.Name())
+		case len(sources) == 1:
+			combined = append(combined, e)
+			continue
+		case strategy == "" && len(overlapping) > 0:
+			return nil, fmt.Errorf("%s is not set: plugin %s fills the same field as %s",
+				strategyEnv, overlapping[0].Name(), e.Name())
+		case strategy == "":
+			combined = append(combined, enricher.Fallback(sources[0], sources[1]))
+			continue
+		}
+
+		weights, err := sourceWeights(sources)
+		if err != nil {
+			return nil, err
+		}
+		arbiter, err := enricher.NewArbiter(strategy, weights, sources...)
+		if err != nil {
+			return nil, err
+		}
+		combined = append(combined, arbiter)
+	}
+
+	for _, p := range plugins {
+		if !merged[p] {
+			combined = append(combined, p)
+		}
+	}
+	return combined, nil
+}
+
+// sourceWeights читает веса источников из переменных ENRICH_WEIGHT_<ИСТОЧНИК>, например ENRICH_WEIGHT_GENDERIZE.
+func sourceWeights(sources []enricher.Enricher) (map[string]float64, error) {
+	weights := make(map[string]float64, len(sources))
+	for _, s := range sources {
+		name := s.Name()
+		if p, ok := s.(interface{ Provider() string }); ok {
+			name = p.Provider()
+		}
+
+		w, err := floatEnv("ENRICH_WEIGHT_"+strings.ToUpper(name), 1)
+		if err != nil {
+			return nil, err
+		}
+		weights[name] = w
+	}
+	return weights, nil
+}
+
+// processEnrichers создаёт обогатители-программы с именами из списка names через запятую.
+// Для каждого обогатителя <ИМЯ> читаются переменные:
+//   - ENRICH_PLUGIN_<ИМЯ>_COMMAND - программа и её аргументы через пробел;
+//   - ENRICH_PLUGIN_<ИМЯ>_FIELDS - заполняемые поля через запятую;
+//   - ENRICH_PLUGIN_<ИМЯ>_REQUIRES - поля, которые нужно заполнить до запуска программы;
+//   - ENRICH_PLUGIN_<ИМЯ>_TIMEOUT - ограничение времени ответа (по умолчанию 5s);
+//   - ENRICH_PLUGIN_<ИМЯ>_CONCURRENCY - число одновременных запросов (по умолчанию 1);
+//   - ENRICH_PLUGIN_<ИМЯ>_KEEPALIVE - не завершать программу между запросами.
+func processEnrichers(names string) ([]*enricher.ProcessEnricher, error) {
+	var plugins []*enricher.ProcessEnricher
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "ENRICH_PLUGIN_" + strings.ToUpper(name) + "_"
+
+		command := strings.Fields(os.Getenv(prefix + "COMMAND"))
+		if len(command) == 0 {
+			return nil, fmt.Errorf("%sCOMMAND is not set", prefix)
+		}
+		cfg := enricher.ProcessConfig{
+			Name:     name,
+			Command:  command[0],
+			Args:     command[1:],
+			Fields:   listEnv(prefix + "FIELDS"),
+			Requires: listEnv(prefix + "REQUIRES"),
+		}
+
+		var err error
+		if cfg.Timeout, err = durationEnv(prefix+"TIMEOUT", 5*time.Second); err != nil {
+			return nil, err
+		}
+		if cfg.MaxConcurrency, err = intEnv(prefix+"CONCURRENCY", 1); err != nil {
+			return nil, err
+		}
+		if cfg.KeepAlive, err = boolEnv(prefix+"KEEPALIVE", false); err != nil {
+			return nil, err
+		}
+
+		p, err := enricher.NewProcessEnricher(cfg)
+		if err != nil {
+			return nil, err
+		}
+		plugins = append(plugins, p)
+	}
+	return plugins, nil
+}
+
+// listEnv читает из переменной окружения name список значений через запятую.
+func listEnv(name string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// newEnricherRegistry создаёт реестр из обогатителей available, перечисленных через запятую в names.
+// Если names пуст, регистрируются все обогатители.
+//
+// Время работы каждого обогатителя ограничивается переменной ENRICH_TIMEOUT_<ИМЯ>
+// или, если она не задана, ENRICH_TIMEOUT. Общее время обогащения ограничивается ENRICH_BUDGET.
+func newEnricherRegistry(names string, available []enricher.Enricher) (*enricher.Registry, error) {
+	builtin, err := enricher.NewRegistry(available...)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := builtin.Names()
+	if strings.TrimSpace(names) != "" {
+		selected = strings.Split(names, ",")
+	}
+
+	timeout, err := durationEnv("ENRICH_TIMEOUT", 3*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	registry, _ := enricher.NewRegistry()
+	for _, name := range selected {
+		e, err := builtin.Get(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := durationEnv("ENRICH_TIMEOUT_"+strings.ToUpper(e.Name()), timeout)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = registry.Register(enricher.WithTimeout(e, t)); err != nil {
+			return nil, err
+		}
+	}
+
+	budget, err := durationEnv("ENRICH_BUDGET", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	registry.SetBudget(budget)
+
+	return registry, nil
+}
+
+// providersConfig читает настройки внешних сервисов обогащения из переменных окружения
+// с префиксами AGIFY, GENDERIZE и NATIONALIZE.
+func providersConfig() (cfg enricher.ProvidersConfig, err error) {
+	if cfg.Agify, err = providerConfig("AGIFY"); err != nil {
+		return cfg, err
+	}
+	if cfg.Genderize, err = providerConfig("GENDERIZE"); err != nil {
+		return cfg, err
+	}
+	if cfg.Nationalize, err = providerConfig("NATIONALIZE"); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// providerConfig читает настройки внешнего сервиса из переменных окружения <PREFIX>_URL, <PREFIX>_API_KEY,
+// <PREFIX>_TIMEOUT, <PREFIX>_USER_AGENT, <PREFIX>_DAILY_QUOTA, <PREFIX>_MIN_PROBABILITY, <PREFIX>_MIN_COUNT
+// и <PREFIX>_LOCALIZE. Локализация по стране пользователя включена по умолчанию.
+// Если переменная <PREFIX>_USER_AGENT не задана, используется ENRICH_USER_AGENT.
+func providerConfig(prefix string) (cfg enricher.ProviderConfig, err error) {
+	cfg.BaseURL = os.Getenv(prefix + "_URL")
+	cfg.APIKey = os.Getenv(prefix + "_API_KEY")
+
+	cfg.UserAgent = os.Getenv(prefix + "_USER_AGENT")
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = os.Getenv("ENRICH_USER_AGENT")
+	}
+
+	if cfg.Timeout, err = durationEnv(prefix+"_TIMEOUT", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.DailyQuota, err = intEnv(prefix+"_DAILY_QUOTA", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.MinProbability, err = floatEnv(prefix+"_MIN_PROBABILITY", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.Localize, err = boolEnv(prefix+"_LOCALIZE", true); err != nil {
+		return cfg, err
+	}
+
+	cfg.MinCount, err = intEnv(prefix+"_MIN_COUNT", 0)
+	return cfg, err
+}
+
+// breakerConfig читает настройки автоматов защиты провайдеров: ENRICH_BREAKER_THRESHOLD ошибок подряд
+// отключают провайдера на ENRICH_BREAKER_COOLDOWN. Квоты провайдеров сохраняются в store.
+func breakerConfig(store enricher.QuotaStore) (cfg enricher.BreakerConfig, err error) {
+	cfg.Store = store
+
+	if cfg.Threshold, err = intEnv("ENRICH_BREAKER_THRESHOLD", 5); err != nil {
+		return cfg, err
+	}
+
+	cfg.Cooldown, err = durationEnv("ENRICH_BREAKER_COOLDOWN", time.Minute)
+	return cfg, err
+}
+
+// newProvidersClient создаёт HTTP-клиент для запросов к внешним сервисам обогащения.
+// Клиент учитывает HTTP_PROXY, HTTPS_PROXY и NO_PROXY; ENRICH_PROXY задаёт прокси только для этих запросов.
+func newProvidersClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy := os.Getenv("ENRICH_PROXY"); proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("ENRICH_PROXY: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// newEnrichmentCache создаёт кэш ответов внешних сервисов обогащения. Размер кэша в памяти задаётся
+// ENRICH_CACHE_SIZE, нулевой размер выключает кэширование. Если ENRICH_CACHE_POSTGRES равна true,
+// кэш дополнительно хранится в базе данных и переживает перезапуски.
+func newEnrichmentCache(db *sql.DB) (*enricher.Cache, error) {
+	size, err := intEnv("ENRICH_CACHE_SIZE", 10000)
+	if err != nil || size <= 0 {
+		return nil, err
+	}
+
+	ttl, err := durationEnv("ENRICH_CACHE_TTL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	negativeTTL, err := durationEnv("ENRICH_CACHE_NEGATIVE_TTL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	var store enricher.CacheStore
+	if os.Getenv("ENRICH_CACHE_POSTGRES") == "true" {
+		store = postgres.NewEnrichmentCacheRepository(db)
+	}
+
+	return enricher.NewCache(size, ttl, negativeTTL, store), nil
+}
+
+// enrichWorkersConfig читает настройки обработчиков заданий на обогащение из переменных окружения.
+func enrichWorkersConfig() (cfg worker.Config, err error) {
+	if cfg.Workers, err = intEnv("ENRICH_WORKERS", 4); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxAttempts, err = intEnv("ENRICH_MAX_ATTEMPTS", 5); err != nil {
+		return cfg, err
+	}
+	if cfg.PollInterval, err = durationEnv("ENRICH_POLL_INTERVAL", time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.BaseBackoff, err = durationEnv("ENRICH_RETRY_BASE", 5*time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxBackoff, err = durationEnv("ENRICH_RETRY_MAX", 10*time.Minute); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// refreshConfig читает настройки фонового повторного обогащения. Оно выключено, пока не задана ENRICH_REFRESH_INTERVAL.
+func refreshConfig() (cfg worker.RefreshConfig, err error) {
+	if cfg.Interval, err = durationEnv("ENRICH_REFRESH_INTERVAL", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.Batch, err = intEnv("ENRICH_REFRESH_BATCH", 20); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxAge, err = durationEnv("ENRICH_REFRESH_MAX_AGE", 30*24*time.Hour); err != nil {
+		return cfg, err
+	}
+	if cfg.RetryAfter, err = durationEnv("ENRICH_REFRESH_RETRY_AFTER", time.Hour); err != nil {
+		return cfg, err
+	}
+	if cfg.QuotaReserve, err = intEnv("ENRICH_REFRESH_QUOTA_RESERVE", 100); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// purgeConfig читает настройки очистки удалённых пользователей. PURGE_INTERVAL=0 выключает очистку.
+func purgeConfig() (cfg worker.PurgeConfig, err error) {
+	if cfg.Interval, err = durationEnv("PURGE_INTERVAL", time.Hour); err != nil {
+		return cfg, err
+	}
+	if cfg.Retention, err = durationEnv("PURGE_RETENTION", 30*24*time.Hour); err != nil {
+		return cfg, err
+	}
+	if cfg.Batch, err = intEnv("PURGE_BATCH", 1000); err != nil {
+		return cfg, err
+	}
+	if cfg.Batch <= 0 {
+		return cfg, fmt.Errorf("PURGE_BATCH: must be positive, got %d", cfg.Batch)
+	}
+	return cfg, nil
+}
+
+// intEnv читает из переменной окружения name целое число. Если переменная не задана, возвращается def.
+func intEnv(name string, def int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return n, nil
+}
+
+// boolEnv читает из переменной окружения name логическое значение. Если переменная не задана, возвращается def.
+func boolEnv(name string, def bool) (bool, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", name, err)
+	}
+	return b, nil
+}
+
+// floatEnv читает из переменной окружения name число с плавающей точкой. Если переменная не задана, возвращается def.
+func floatEnv(name string, def float64) (float64, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return f, nil
+}
+
+// durationEnv читает из переменной окружения name длительность в формате time.ParseDuration.
+// Если переменная не задана, возвращается def.
+func durationEnv(name string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return d, nil
+}
+
 func (app *App) Shutdown(ctx context.Context) error {
 	err := app.srv.Shutdown(ctx)
 	if err != nil {
 		return err
 	}
 
+	if app.refreshSweeper != nil {
+		err = app.refreshSweeper.Shutdown(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if app.purgeSweeper != nil {
+		err = app.purgeSweeper.Shutdown(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if app.enrichWorkers != nil {
+		err = app.enrichWorkers.Shutdown(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, p := range app.plugins {
+		p.Close()
+	}
+
+	if app.fakeProviders != nil {
+		err = app.fakeProviders.Shutdown(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = app.db.Close()
 	if err != nil {
 		return err
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/aachex/service/internal/enricher"
+	"github.com/aachex/service/internal/logging"
+	"github.com/aachex/service/internal/model"
+	"github.com/aachex/service/internal/pagination"
+)
+
+type enrichmentCache interface {
+	Stats() enricher.CacheStats
+}
+
+type conflictsRepository interface {
+	GetConflicts(ctx context.Context, field string, offset, limit int) ([]model.EnrichmentConflict, error)
+}
+
+// EnrichmentController отдаёт операторам сведения о работе обогащения.
+type EnrichmentController struct {
+	cache     enrichmentCache
+	breakers  []*enricher.Breaker
+	conflicts conflictsRepository
+	logger    *slog.Logger
+}
+
+// NewEnrichmentController создаёт контроллер. cache может быть nil, если кэширование выключено.
+// breakers - автоматы защиты провайдеров, состояние которых показывается операторам.
+func NewEnrichmentController(
+	cache enrichmentCache,
+	breakers []*enricher.Breaker,
+	conflicts conflictsRepository,
+	l *slog.Logger,
+) *EnrichmentController {
+	return &EnrichmentController{
+		cache:     cache,
+		breakers:  breakers,
+		conflicts: conflicts,
+		logger:    l,
+	}
+}
+
+func (c *EnrichmentController) RegisterHandlers(mux *http.ServeMux) {
+	prefix := "/api/v1"
+
+	mux.HandleFunc(
+		"GET "+prefix+"/enrichment/cache",
+		logging.Middleware(c.logger, c.GetCacheStats))
+
+	mux.HandleFunc(
+		"GET "+prefix+"/enrichment/providers",
+		logging.Middleware(c.logger, c.GetProviders))
+
+	mux.HandleFunc(
+		"GET "+prefix+"/enrichment/conflicts",
+		logging.Middleware(c.logger, pagination.Middleware(c.GetConflicts)))
+}
+
+//	@summary	Статистика кэша ответов внешних сервисов обогащения.
+//	@produce	json
+//	@success	200	{object}	enricher.CacheStats
+//	@failure	404	"Кэширование выключено"
+//	@router		/enrichment/cache [get]
+func (c *EnrichmentController) GetCacheStats(w http.ResponseWriter, r *http.Request) {
+	if c.cache == nil {
+		http.Error(w, "enrichment cache is disabled", http.StatusNotFound)
+		return
+	}
+
+	writeReponse(c.cache.Stats(), w)
+}
+
+//	@summary		Состояние внешних сервисов обогащения.
+//	@description	Для каждого провайдера возвращает состояние автомата защиты (closed, open, half_open),
+//	@description	причину отключения и остаток суточной квоты.
+//	@produce		json
+//	@success		200	{array}	enricher.BreakerStatus
+//	@router			/enrichment/providers [get]
+func (c *EnrichmentController) GetProviders(w http.ResponseWriter, r *http.Request) {
+	statuses := make([]enricher.BreakerStatus, 0, len(c.breakers))
+	for _, b := range c.breakers {
+		statuses = append(statuses, b.Status())
+	}
+
+	writeReponse(statuses, w)
+}
+
+//	@summary		Расхождения источников обогащения.
+//	@description	Возвращает от новых к старым случаи, когда источники предложили для поля разные значения,
+//	@description	вместе с выбранным значением и стратегией выбора.
+//	@produce		json
+//	@param			offset	query	integer	true	"offset"
+//	@param			limit	query	integer	true	"limit"
+//	@param			field	query	string	false	"Поле, например gender"
+//	@success		200		{array}	model.EnrichmentConflict
+//	@router			/enrichment/conflicts [get]
+func (c *EnrichmentController) GetConflicts(w http.ResponseWriter, r *http.Request) {
+	pag := r.Context().Value(pagination.CtxKey("pagination")).(pagination.Pagination)
+
+	conflicts, err := c.conflicts.GetConflicts(r.Context(), r.URL.Query().Get("field"), pag.Offset, pag.Limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeReponse(conflicts, w)
+}
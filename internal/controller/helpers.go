@@ -2,8 +2,11 @@ package controller
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 // readBody получает из тела запроса в формате json структуру T.
@@ -34,3 +37,29 @@ func writeReponse[T any](obj T, w http.ResponseWriter) {
 
 	w.Write(b)
 }
+
+// splitList разбивает строку со значениями через запятую, пропуская пустые значения.
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// limitParam возвращает параметр запроса limit: def, если он не задан, и не больше max.
+func limitParam(r *http.Request, def, max int) (int, error) {
+	s := r.URL.Query().Get("limit")
+	if s == "" {
+		return def, nil
+	}
+
+	limit, err := strconv.Atoi(s)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("limit must be a positive integer, got %q", s)
+	}
+	return min(limit, max), nil
+}
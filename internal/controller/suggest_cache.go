@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aachex/service/internal/model"
+)
+
+// suggestCache хранит подсказки в памяти недолго: пока оператор набирает значение, одни и те же
+// начала запрашиваются много раз, а небольшое отставание от базы для подсказок не важно.
+// Изменения пользователей через этот экземпляр сервиса сбрасывают кэш методом clear.
+type suggestCache struct {
+	ttl      time.Duration
+	capacity int
+
+	mu      sync.Mutex
+	entries map[string]suggestEntry
+
+	now func() time.Time
+}
+
+type suggestEntry struct {
+	suggestions []model.Suggestion
+	limit       int // сколько подсказок запрашивалось у базы
+	expiresAt   time.Time
+}
+
+// newSuggestCache создаёт кэш на capacity записей, каждая из которых хранится ttl.
+// Кэш с ttl 0 ничего не хранит.
+func newSuggestCache(ttl time.Duration, capacity int) *suggestCache {
+	return &suggestCache{
+		ttl:      ttl,
+		capacity: capacity,
+		entries:  make(map[string]suggestEntry),
+		now:      time.Now,
+	}
+}
+
+// get возвращает подсказки по ключу, если они есть, не устарели и их хватает для limit подсказок:
+// запись, полученная с меньшим ограничением, подходит, только если в базе больше подсказок нет.
+func (c *suggestCache) get(key string, limit int) ([]model.Suggestion, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(e.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	if limit > e.limit && len(e.suggestions) == e.limit {
+		return nil, false
+	}
+	return e.suggestions, true
+}
+
+// set сохраняет подсказки, полученные из базы с ограничением limit, по ключу. Если кэш заполнен,
+// из него сначала удаляются устаревшие записи, а если таких нет - произвольная.
+func (c *suggestCache) set(key string, limit int, suggestions []model.Suggestion) {
+	if c.ttl <= 0 || c.capacity <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.capacity {
+		for k, e := range c.entries {
+			if !now.Before(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		for k := range c.entries {
+			if len(c.entries) < c.capacity {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = suggestEntry{suggestions: suggestions, limit: limit, expiresAt: now.Add(c.ttl)}
+}
+
+// clear удаляет из кэша все записи.
+func (c *suggestCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.entries)
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aachex/service/internal/model"
+)
+
+func TestSuggestCache(t *testing.T) {
+	now := time.Now()
+	c := newSuggestCache(time.Minute, 2)
+	c.now = func() time.Time { return now }
+
+	ivanov := []model.Suggestion{{Value: "Иванов", Count: 3}}
+	c.set("surname:ив", 10, ivanov)
+	if got, ok := c.get("surname:ив", 10); !ok || len(got) != 1 || got[0] != ivanov[0] {
+		t.Errorf("wanted cached suggestions, got %v, %v", got, ok)
+	}
+
+	// при переполнении сначала удаляются устаревшие записи
+	now = now.Add(2 * time.Minute)
+	c.set("surname:пе", 10, nil)
+	c.set("surname:си", 10, nil)
+	if _, ok := c.get("surname:ив", 10); ok {
+		t.Error("entry outlived ttl")
+	}
+	if _, ok := c.get("surname:пе", 10); !ok {
+		t.Error("fresh entry was evicted instead of expired one")
+	}
+
+	c.set("surname:ко", 10, nil)
+	if n := len(c.entries); n != 2 {
+		t.Errorf("wanted cache size to stay at 2, got %d", n)
+	}
+}
+
+func TestSuggestCacheDisabled(t *testing.T) {
+	c := newSuggestCache(0, 10)
+	c.set("surname:ив", 10, []model.Suggestion{{Value: "Иванов", Count: 1}})
+	if _, ok := c.get("surname:ив", 10); ok {
+		t.Error("cache with zero ttl stored an entry")
+	}
+}
+
+
+func TestSuggestCacheLimit(t *testing.T) {
+	c := newSuggestCache(time.Minute, 10)
+
+	// все подсказки помещаются в ограничение - в базе больше нет, запись подходит для любого limit
+	c.set("surname:ив", 2, []model.Suggestion{{Value: "Иванов", Count: 3}})
+	if _, ok := c.get("surname:ив", 50); !ok {
+		t.Error("complete entry wasn't used for a larger limit")
+	}
+
+	// подсказки упёрлись в ограничение - для большего limit их может не хватить
+	c.set("surname:пе", 1, []model.Suggestion{{Value: "Петров", Count: 5}})
+	if _, ok := c.get("surname:пе", 1); !ok {
+		t.Error("entry wasn't used for the same limit")
+	}
+	if _, ok := c.get("surname:пе", 2); ok {
+		t.Error("truncated entry was used for a larger limit")
+	}
+}
+
+func TestSuggestCacheClear(t *testing.T) {
+	c := newSuggestCache(time.Minute, 10)
+	c.set("surname:ив", 10, []model.Suggestion{{Value: "Иванов", Count: 1}})
+	c.clear()
+	if _, ok := c.get("surname:ив", 10); ok {
+		t.Error("entry survived clear")
+	}
+}
@@ -2,9 +2,13 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aachex/service/internal/enricher"
 	"github.com/aachex/service/internal/logging"
@@ -13,24 +17,69 @@ import (
 )
 
 type usersRepository interface {
-	GetFiltered(ctx context.Context, filter map[string][]any, offset, limit int) ([]model.User, error)
-	Create(ctx context.Context, name, surname, patronymic string, age int, gender, nationality string) (int64, error)
+	GetFiltered(ctx context.Context, filter model.Filter, page model.Page) ([]model.User, *model.Keyset, error)
+	CountFiltered(ctx context.Context, filter model.Filter, includeDeleted bool) (int, error)
+	GetFacets(ctx context.Context, filter model.Filter, includeDeleted bool) (model.Facets, error)
+	Search(ctx context.Context, q string, limit int) ([]model.SearchResult, error)
+	Suggest(ctx context.Context, field, prefix string, limit int) ([]model.Suggestion, error)
+	GetById(ctx context.Context, id int64) (model.User, error)
+	LoadProvenance(ctx context.Context, users []model.User) error
+	Create(ctx context.Context, user model.User) (int64, error)
+	CreateAndEnqueue(ctx context.Context, user model.User, enrichers []string) (int64, error)
+	Enqueue(ctx context.Context, id int64, enrichers []string) error
 	Update(ctx context.Context, id int64, updates map[string]any) error
+	Import(ctx context.Context, id int64, updates map[string]any) error
+	SaveEnrichment(ctx context.Context, id int64, changes model.Enrichment) error
+	GetNationalities(ctx context.Context, id int64) ([]model.Nationality, error)
+	Unlock(ctx context.Context, id int64, fields ...string) (int64, error)
 	Delete(ctx context.Context, uid int64) error
+	Restore(ctx context.Context, uid int64) (bool, error)
+}
+
+type enricherRegistry interface {
+	Select(names ...string) (enricher.Enricher, error)
+	Omit(names []string, fields ...string) []string
 }
 
 type UsersController struct {
-	users  usersRepository
-	logger *slog.Logger
+	users     usersRepository
+	enrichers enricherRegistry
+	cursors   *pagination.CursorCodec
+	suggest   *suggestCache
+	logger    *slog.Logger
+
+	// asyncEnrichment включает отложенное обогащение: пользователь создаётся сразу,
+	// а обогащается позже обработчиком заданий.
+	asyncEnrichment bool
 }
 
-func NewUsersController(ur usersRepository, l *slog.Logger) *UsersController {
+func NewUsersController(ur usersRepository, er enricherRegistry, l *slog.Logger) *UsersController {
 	return &UsersController{
-		users:  ur,
-		logger: l,
+		users:     ur,
+		enrichers: er,
+		cursors:   pagination.NewCursorCodec(nil),
+		suggest:   newSuggestCache(defaultSuggestCacheTTL, suggestCacheSize),
+		logger:    l,
 	}
 }
 
+// SetCursorKey задаёт ключ, которым подписываются курсоры страниц. Без него ключ случайный,
+// и курсоры перестают действовать после перезапуска сервиса.
+func (c *UsersController) SetCursorKey(key []byte) {
+	c.cursors = pagination.NewCursorCodec(key)
+}
+
+// SetSuggestCacheTTL задаёт, сколько подсказки хранятся в памяти. 0 выключает кэш подсказок.
+func (c *UsersController) SetSuggestCacheTTL(ttl time.Duration) {
+	c.suggest = newSuggestCache(ttl, suggestCacheSize)
+}
+
+// EnableAsyncEnrichment переводит создание пользователей в асинхронный режим: CreateUser сохраняет
+// пользователя вместе с заданием на обогащение и отвечает 202, не дожидаясь внешних сервисов.
+func (c *UsersController) EnableAsyncEnrichment() {
+	c.asyncEnrichment = true
+}
+
 func (c *UsersController) RegisterHandlers(mux *http.ServeMux) {
 	prefix := "/api/v1"
 
@@ -42,6 +91,26 @@ func (c *UsersController) RegisterHandlers(mux *http.ServeMux) {
 		"POST "+prefix+"/users/get",
 		logging.Middleware(c.logger, pagination.Middleware(c.GetUsers)))
 
+	mux.HandleFunc(
+		"GET "+prefix+"/users/search",
+		logging.Middleware(c.logger, c.SearchUsers))
+
+	mux.HandleFunc(
+		"GET "+prefix+"/users/suggest",
+		logging.Middleware(c.logger, c.SuggestUsers))
+
+	mux.HandleFunc(
+		"POST "+prefix+"/users/{id}/enrich",
+		logging.Middleware(c.logger, c.EnrichUser))
+
+	mux.HandleFunc(
+		"GET "+prefix+"/users/{id}/nationalities",
+		logging.Middleware(c.logger, c.GetNationalities))
+
+	mux.HandleFunc(
+		"DELETE "+prefix+"/users/{id}/locks/{field}",
+		logging.Middleware(c.logger, c.UnlockField))
+
 	mux.HandleFunc(
 		"PATCH "+prefix+"/users/upd/{id}",
 		logging.Middleware(c.logger, c.UpdateUser))
@@ -49,46 +118,250 @@ func (c *UsersController) RegisterHandlers(mux *http.ServeMux) {
 	mux.HandleFunc(
 		"DELETE "+prefix+"/users/delete/{id}",
 		logging.Middleware(c.logger, c.DeleteUser))
+
+	mux.HandleFunc(
+		"POST "+prefix+"/users/{id}/restore",
+		logging.Middleware(c.logger, c.RestoreUser))
 }
 
-//	@summary	Получение пользователей с возможностью фильтрации по полям.
-//	@produce	json
-//	@success	200
-//	@param		offset	query	integer				true	"offset"
-//	@param		limit	query	integer				true	"limit"
-//	@param		request	body	map[string][]any	true	"filter"
-//	@router		/users/get [post]
+//	@summary		Получение пользователей с возможностью фильтрации по полям.
+//	@description	Фильтр - условие {"field": "age", "op": "gte", "value": 25}, группа {"and": [...]} или {"or": [...]},
+//	@description	отрицание {"not": {...}} либо отбор по странам-кандидатам {"candidate": {"countries": ["RU"], "min_probability": 0.3}}.
+//	@description	Операторы: eq (по умолчанию), ne, gt, gte, lt, lte, in, not_in (value - список), prefix, contains, ilike (для строк)
+//	@description	и is_null (value - true или false). Например, возраст от 25 до 40 и фамилия на «Ив»:
+//	@description	{"and": [{"field": "age", "op": "gte", "value": 25}, {"field": "age", "op": "lte", "value": 40}, {"field": "surname", "op": "prefix", "value": "Ив"}]}
+//	@description	Поддерживается и прежний формат {"поле": [значения]}: значения поля объединяются через OR, поля - через AND,
+//	@description	ключи min_<поле> задают минимальную уверенность сервиса (например min_gender_probability), а nationality_candidate и min_candidate_probability отбирают по странам-кандидатам.
+//	@description	Удалённые пользователи не выбираются, если не задан параметр include_deleted=true.
+//	@description	Пользователи сначала фильтруются, затем упорядочиваются по sort и id и делятся на страницы.
+//	@description	Если есть следующая страница, её курсор возвращается в заголовке X-Next-Cursor;
+//	@description	запрос с параметром cursor вместо offset продолжает выборку с места, где закончилась предыдущая страница.
+//	@description	Общее число подходящих под фильтр пользователей возвращается в заголовке X-Total-Count. С параметром envelope=true
+//	@description	ответ - объект usersPage с пользователями, их общим числом и курсором следующей страницы,
+//	@description	а с include=facets в него добавляется число пользователей по полу, национальности и диапазонам возраста.
+//	@produce		json
+//	@success		200		{object}	usersPage
+//	@header			200		{string}	X-Next-Cursor	"курсор следующей страницы, если она есть"
+//	@header			200		{integer}	X-Total-Count	"число пользователей, подходящих под фильтр"
+//	@failure		400		"Неизвестное поле или оператор фильтра либо значение неподходящего типа"
+//	@param			offset	query	integer			false	"offset; не нужен, если задан cursor"
+//	@param			limit	query	integer			true	"limit"
+//	@param			cursor	query	string			false	"курсор следующей страницы из заголовка X-Next-Cursor"
+//	@param			sort	query	string			false	"Сортировка: поля через запятую в формате поле[:asc|desc][:nulls_first|nulls_last], например nationality,age:desc"
+//	@param			envelope	query	boolean			false	"true - вернуть объект usersPage вместо массива пользователей"
+//	@param			include_deleted	query	boolean	false	"true - выбирать и удалённых пользователей"
+//	@param			include	query	string			false	"Через запятую: provenance - добавить происхождение значений полей, facets - добавить фасеты в usersPage"
+//	@param			request	body	model.Filter	true	"filter"
+//	@router			/users/get [post]
 func (c *UsersController) GetUsers(w http.ResponseWriter, r *http.Request) {
 	// Пагинация
 	pag := r.Context().Value(pagination.CtxKey("pagination")).(pagination.Pagination)
 
-	filter, err := readBody[map[string][]any](r)
+	filter, err := readBody[model.Filter](r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	users, err := c.users.GetFiltered(r.Context(), filter, pag.Offset, pag.Limit)
+	sort, err := model.ParseSort(r.URL.Query().Get("sort"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page := model.Page{
+		Offset:         pag.Offset,
+		Limit:          pag.Limit,
+		Sort:           sort,
+		IncludeDeleted: r.URL.Query().Get("include_deleted") == "true",
+	}
+	if pag.Cursor != "" {
+		page.After = &model.Keyset{}
+		if err = c.cursors.Decode(pag.Cursor, page.After); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	users, next, err := c.users.GetFiltered(r.Context(), filter, page)
+	if errors.Is(err, model.ErrInvalidField) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
-	writeReponse(users, w)
+	if included(r, "provenance") {
+		if err = c.users.LoadProvenance(r.Context(), users); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	resp := usersPage{Items: users}
+	if resp.Total, err = c.users.CountFiltered(r.Context(), filter, page.IncludeDeleted); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(resp.Total))
+
+	if next != nil {
+		if resp.NextCursor, err = c.cursors.Encode(next); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("X-Next-Cursor", resp.NextCursor)
+	}
+
+	if r.URL.Query().Get("envelope") != "true" {
+		writeReponse(users, w)
+		return
+	}
+
+	if included(r, "facets") {
+		facets, err := c.users.GetFacets(r.Context(), filter, page.IncludeDeleted)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp.Facets = &facets
+	}
+	writeReponse(resp, w)
+}
+
+// usersPage - страница пользователей вместе с общим числом подходящих под фильтр.
+type usersPage struct {
+	Items []model.User `json:"items"`
+	Total int          `json:"total"`
+
+	// Facets - число подходящих под фильтр пользователей по значениям полей. Есть только при include=facets.
+	Facets *model.Facets `json:"facets,omitempty"`
+
+	// NextCursor - курсор следующей страницы, если она есть.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Ограничения на число пользователей в ответе поиска.
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+//	@summary		Поиск пользователей по ФИО.
+//	@description	Находит пользователей, ФИО которых похоже на запрос, и возвращает их по убыванию сходства.
+//	@description	Кириллица и латиница взаимозаменяемы (Иванова и Ivanova), опечатки допускаются,
+//	@description	а последнее слово может быть началом слова: «Иванова Мар». Найденные слова в полях name, surname
+//	@description	и patronymic выделяются тегом <mark> в highlight.
+//	@produce		json
+//	@param			q		query		string	true	"Запрос, например Иванова Мар"
+//	@param			limit	query		integer	false	"Сколько пользователей вернуть: по умолчанию 20, не больше 100"
+//	@success		200		{array}		model.SearchResult
+//	@failure		400		"Пустой запрос или неверный limit"
+//	@router			/users/search [get]
+func (c *UsersController) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := limitParam(r, defaultSearchLimit, maxSearchLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := c.users.Search(r.Context(), q, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeReponse(results, w)
+}
+
+// Настройки подсказок при вводе.
+const (
+	defaultSuggestLimit    = 10
+	maxSuggestLimit        = 50
+	defaultSuggestCacheTTL = 30 * time.Second
+	suggestCacheSize       = 10000
+)
+
+//	@summary		Подсказки при вводе имени, фамилии или отчества.
+//	@description	Возвращает различные значения поля, начинающиеся с prefix без учёта регистра, и число пользователей
+//	@description	с каждым из них, начиная с самых частых. Подсказки кэшируются на короткое время. Кэш сбрасывается,
+//	@description	когда этот экземпляр сервиса добавляет, изменяет, удаляет или восстанавливает пользователей,
+//	@description	а изменения через другие экземпляры могут появиться в подсказках не сразу.
+//	@produce		json
+//	@param			field	query		string	false	"name, surname (по умолчанию) или patronymic"
+//	@param			prefix	query		string	true	"Начало значения, например Ив"
+//	@param			limit	query		integer	false	"Сколько подсказок вернуть: по умолчанию 10, не больше 50"
+//	@success		200		{array}		model.Suggestion
+//	@failure		400		"Неизвестное поле, пустой prefix или неверный limit"
+//	@router			/users/suggest [get]
+func (c *UsersController) SuggestUsers(w http.ResponseWriter, r *http.Request) {
+	field := r.URL.Query().Get("field")
+	if field == "" {
+		field = "surname"
+	}
+
+	prefix := strings.TrimSpace(r.URL.Query().Get("prefix"))
+	if prefix == "" {
+		http.Error(w, "prefix is required", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := limitParam(r, defaultSuggestLimit, maxSuggestLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// в кэше хранится наибольшее число подсказок, чтобы запросы с разным limit не расходились
+	key := field + ":" + strings.ToLower(prefix)
+	suggestions, ok := c.suggest.get(key, limit)
+	if !ok {
+		suggestions, err = c.users.Suggest(r.Context(), field, prefix, maxSuggestLimit)
+		if errors.Is(err, model.ErrInvalidField) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		c.suggest.set(key, maxSuggestLimit, suggestions)
+	}
+
+	writeReponse(suggestions[:min(limit, len(suggestions))], w)
 }
 
 type reqBody struct {
 	Name       string `json:"name"`
 	Surname    string `json:"surname"`
 	Patronymic string `json:"patronymic"`
+
+	// Nationality - код страны пользователя, если он известен. Тогда национальность не определяется
+	// внешним сервисом, а возраст и пол уточняются по этой стране.
+	Nationality string `json:"nationality"`
 }
 
-//	@summary	Создание нового пользователя в базе данных.
-//	@accept		json
-//	@produce	json
-//	@param		request	body		reqBody	true	"Request"
-//	@success	200		{object}	model.User
-//	@router		/users/new [post]
+//	@summary		Создание нового пользователя в базе данных.
+//	@description	Ответ содержит итоги обогащения по полям: ok, no_data (сервис не знает значения),
+//	@description	provider_error (сервис ответил ошибкой) или skipped (к сервису не обращались).
+//	@description	В асинхронном режиме пользователь сохраняется без обогащения, ответ имеет код 202,
+//	@description	а данные из внешних сервисов появляются позже.
+//	@accept			json
+//	@produce		json
+//	@param			enrich	query		string	false	"Обогатители через запятую, например age,gender. По умолчанию используются все"
+//	@param			include	query		string	false	"provenance - добавить происхождение значений полей"
+//	@param			request	body		reqBody	true	"Request"
+//	@success		201		{object}	userResponse
+//	@success		202		{object}	userResponse
+//	@router			/users/new [post]
 func (c *UsersController) CreateUser(w http.ResponseWriter, r *http.Request) {
 	body, err := readBody[reqBody](r)
 	if err != nil {
@@ -102,30 +375,222 @@ func (c *UsersController) CreateUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	user := model.User{
-		Name:       body.Name,
-		Surname:    body.Surname,
-		Patronymic: body.Patronymic,
+		Name:        body.Name,
+		Surname:     body.Surname,
+		Patronymic:  body.Patronymic,
+		Nationality: strings.ToUpper(body.Nationality),
 	}
 
-	enricher.EnrichUser(&user)
+	if user.Nationality != "" {
+		user.SetProvenance("nationality", model.Provenance{Source: model.SourceOperator, UpdatedAt: time.Now(), Locked: true})
+	}
+
+	var report enricher.Report
+	names, enabled := enricherNames(r)
+	if enabled && user.Nationality != "" {
+		// явно указанную национальность не перезаписываем
+		names = c.enrichers.Omit(names, "nationality")
+		enabled = len(names) > 0
+	}
+	if enabled {
+		enrich, err := c.enrichers.Select(names...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if c.asyncEnrichment {
+			user.Id, err = c.users.CreateAndEnqueue(r.Context(), user, names)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			c.suggest.clear()
+
+			w.WriteHeader(http.StatusAccepted)
+			writeReponse(user, w)
+			return
+		}
+
+		report = c.enrich(r.Context(), enrich, &user)
+	}
 
-	id, err := c.users.Create(r.Context(), user.Name, user.Surname, user.Patronymic, user.Age, user.Gender, user.Nationality)
+	id, err := c.users.Create(r.Context(), user)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	user.Id = id
+	c.suggest.clear()
+
+	if !included(r, "provenance") {
+		user.Provenance = nil
+	}
 
 	w.WriteHeader(http.StatusCreated)
-	writeReponse(user, w)
+	writeReponse(userResponse{User: user, Enrichment: report}, w)
+}
+
+// userResponse - пользователь вместе с итогами его обогащения.
+type userResponse struct {
+	model.User
+
+	// Enrichment - итоги обогащения по полям. Отсутствует, если обогащение не выполнялось или отложено.
+	Enrichment enricher.Report `json:"enrichment,omitempty"`
+}
+
+// enrich обогащает пользователя, отмечает время обогащения и записывает в лог поля, которые не удалось заполнить.
+func (c *UsersController) enrich(ctx context.Context, e enricher.Enricher, user *model.User) enricher.Report {
+	report := enricher.NewReport(e, e.Enrich(ctx, user))
+
+	now := time.Now()
+	user.EnrichedAt = &now
+
+	for field, o := range report {
+		if o.Failed() {
+			c.logger.Warn("enrichment failed",
+				slog.Int64("userId", user.Id),
+				slog.String("field", field),
+				slog.String("provider", o.Provider),
+				slog.String("status", o.Status),
+				slog.String("reason", o.Reason))
+		}
+	}
+	return report
+}
+
+// enricherNames возвращает имена обогатителей из параметра запроса enrich. Пустой список означает все обогатители.
+// Если параметр передан с пустым значением, обогащение отключается и возвращается false.
+func enricherNames(r *http.Request) ([]string, bool) {
+	if !r.URL.Query().Has("enrich") {
+		return nil, true
+	}
+
+	names := splitList(r.URL.Query().Get("enrich"))
+	return names, len(names) > 0
 }
 
-//	@summary	Обновляет указанные данные у пользователя по id.
-//	@accept		json
-//	@success	200
-//	@param		id		path	integer		true	"User ID"
-//	@param		request	body	model.User	true	"Request"
-//	@router		/users/upd/{id} [patch]
+//	@summary		Повторное обогащение пользователя по id.
+//	@description	Перезаписывает поля, для которых внешние сервисы вернули данные; остальные поля не меняются.
+//	@description	Значения, заданные оператором или импортом, не перезаписываются, их поля получают статус skipped.
+//	@description	В асинхронном режиме ставит задание в очередь и отвечает 202.
+//	@produce		json
+//	@param			id		path		integer	true	"User ID"
+//	@param			enrich	query		string	false	"Обогатители через запятую, например age,gender. По умолчанию используются все"
+//	@param			include	query		string	false	"provenance - добавить происхождение значений полей"
+//	@success		200		{object}	userResponse
+//	@success		202		{object}	userResponse
+//	@failure		404
+//	@router			/users/{id}/enrich [post]
+func (c *UsersController) EnrichUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	names, enabled := enricherNames(r)
+	if !enabled {
+		http.Error(w, "no enrichers specified", http.StatusBadRequest)
+		return
+	}
+
+	enrich, err := c.enrichers.Select(names...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := c.users.GetById(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if user.Id == 0 {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	if c.asyncEnrichment {
+		if err = c.users.Enqueue(r.Context(), id, names); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if !included(r, "provenance") {
+			user.Provenance = nil
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		writeReponse(user, w)
+		return
+	}
+
+	report := c.enrich(r.Context(), enrich, &user)
+
+	// сохраняем то, что удалось получить, даже если часть обогатителей завершилась с ошибкой
+	changes := enricher.Changes(&user, enrich.Fields())
+	changes.Values["enriched_at"] = *user.EnrichedAt
+	err = c.users.SaveEnrichment(r.Context(), id, changes)
+	if errors.Is(err, model.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !included(r, "provenance") {
+		user.Provenance = nil
+	}
+
+	writeReponse(userResponse{User: user, Enrichment: report}, w)
+}
+
+//	@summary		Страны-кандидаты пользователя.
+//	@description	Возвращает все страны, которые внешний сервис предложил для пользователя, по убыванию вероятности.
+//	@produce		json
+//	@param			id	path		integer	true	"User ID"
+//	@success		200	{array}		model.Nationality
+//	@failure		404
+//	@router			/users/{id}/nationalities [get]
+func (c *UsersController) GetNationalities(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := c.users.GetById(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if user.Id == 0 {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	nationalities, err := c.users.GetNationalities(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeReponse(nationalities, w)
+}
+
+//	@summary		Обновляет указанные данные у пользователя по id.
+//	@description	Обновлённые поля блокируются: обогатители не перезаписывают их до разблокировки.
+//	@accept			json
+//	@success		200
+//	@failure		400	"Поле нельзя изменить или значение неподходящего типа"
+//	@failure		404	"Пользователь не найден или удалён"
+//	@param			id		path	integer		true	"User ID"
+//	@param			source	query	string		false	"Источник значений: operator (по умолчанию) или import"
+//	@param			request	body	model.User	true	"Request"
+//	@router			/users/upd/{id} [patch]
 func (c *UsersController) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
@@ -139,16 +604,65 @@ func (c *UsersController) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = c.users.Update(r.Context(), id, updates)
+	// значения, заданные вручную, блокируются от перезаписи обогатителями
+	if r.URL.Query().Get("source") == model.SourceImport {
+		err = c.users.Import(r.Context(), id, updates)
+	} else {
+		err = c.users.Update(r.Context(), id, updates)
+	}
+	if errors.Is(err, model.ErrInvalidField) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, model.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	c.suggest.clear()
+}
+
+//	@summary		Разблокирует поле пользователя.
+//	@description	После разблокировки обогатители снова могут перезаписывать значение поля.
+//	@success		204
+//	@failure		404
+//	@param			id		path	integer	true	"User ID"
+//	@param			field	path	string	true	"Поле, например age"
+//	@router			/users/{id}/locks/{field} [delete]
+func (c *UsersController) UnlockField(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
+
+	n, err := c.users.Unlock(r.Context(), id, r.PathValue("field"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "field is not locked", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// included сообщает, перечислено ли name в параметре запроса include.
+func included(r *http.Request, name string) bool {
+	return slices.Contains(splitList(r.URL.Query().Get("include")), name)
 }
 
-//	@summary	Удаление пользователя по id.
-//	@success	200
-//	@param		id	path	integer	true	"User ID"
-//	@router		/users/delete/{id} [delete]
+//	@summary		Удаление пользователя по id.
+//	@description	Пользователь помечается удалённым и перестаёт выбираться, но его можно восстановить
+//	@description	запросом /users/{id}/restore, пока он не очищен окончательно по истечении срока хранения.
+//	@success		200
+//	@param			id	path	integer	true	"User ID"
+//	@router			/users/delete/{id} [delete]
 func (c *UsersController) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
@@ -161,4 +675,31 @@ func (c *UsersController) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	c.suggest.clear()
+}
+
+//	@summary		Восстановление удалённого пользователя по id.
+//	@success		204
+//	@failure		404	"Пользователь не найден, не удалён или уже очищен"
+//	@param			id	path	integer	true	"User ID"
+//	@router			/users/{id}/restore [post]
+func (c *UsersController) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	restored, err := c.users.Restore(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !restored {
+		http.Error(w, "user is not deleted", http.StatusNotFound)
+		return
+	}
+	c.suggest.clear()
+
+	w.WriteHeader(http.StatusNoContent)
 }
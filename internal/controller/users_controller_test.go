@@ -11,6 +11,8 @@ import (
 	"strconv"
 	"testing"
 
+	"github.com/aachex/service/internal/enricher"
+	"github.com/aachex/service/internal/enricher/fake"
 	"github.com/aachex/service/internal/model"
 	"github.com/aachex/service/internal/pagination"
 	"github.com/aachex/service/internal/repository/postgres"
@@ -30,7 +32,7 @@ func TestGetUsers(t *testing.T) {
 
 	w := httptest.NewRecorder()
 
-	c := NewUsersController(users, nil)
+	c := NewUsersController(users, newEnricherRegistry(t), nil)
 	pagination.Middleware(c.GetUsers)(w, r)
 
 	if w.Result().StatusCode != http.StatusOK {
@@ -50,7 +52,7 @@ func TestCreateAndDeleteUser(t *testing.T) {
 
 	w := httptest.NewRecorder()
 
-	c := NewUsersController(users, nil)
+	c := NewUsersController(users, newEnricherRegistry(t), nil)
 	c.CreateUser(w, r)
 
 	if w.Result().StatusCode != http.StatusCreated {
@@ -82,6 +84,11 @@ func TestCreateAndDeleteUser(t *testing.T) {
 	if w.Result().StatusCode != http.StatusOK {
 		t.Errorf("Wanted status code 200, got %d", w.Result().StatusCode)
 	}
+
+	// удалённый пользователь остаётся в базе до очистки
+	if err = users.Purge(t.Context(), createdUser.Id); err != nil {
+		t.Error(err)
+	}
 }
 
 // helpers
@@ -100,3 +107,19 @@ func loadEnv(t *testing.T) {
 		t.Error("Failed to load .env file")
 	}
 }
+
+// newEnricherRegistry возвращает реестр встроенных обогатителей, которые обращаются к фейковым сервисам.
+func newEnricherRegistry(t *testing.T) *enricher.Registry {
+	srv := fake.NewServer(fake.DefaultTable())
+	t.Cleanup(srv.Close)
+
+	registry, err := enricher.NewRegistry(enricher.Builtin(enricher.ProvidersConfig{
+		Agify:       enricher.ProviderConfig{BaseURL: srv.AgifyURL()},
+		Genderize:   enricher.ProviderConfig{BaseURL: srv.GenderizeURL()},
+		Nationalize: enricher.ProviderConfig{BaseURL: srv.NationalizeURL()},
+	})...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return registry
+}
@@ -0,0 +1,214 @@
+package enricher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aachex/service/internal/model"
+)
+
+// Стратегии выбора значения поля, которое предлагают несколько источников.
+const (
+	StrategyPriority   = "priority"   // значение первого источника, у которого есть данные
+	StrategyConfidence = "confidence" // значение источника, наиболее уверенного в нём
+	StrategyVote       = "vote"       // значение с наибольшей суммой уверенностей источников, умноженных на их веса
+)
+
+// NewArbiter создаёт обогатитель, который опрашивает все источники sources одновременно и выбирает
+// значение поля по стратегии strategy. Источники должны заполнять одно и то же единственное поле
+// и перечисляются в порядке приоритета. weights - веса источников по их именам для StrategyVote,
+// по умолчанию вес равен 1.
+//
+// Если источники предложили разные значения, расхождение добавляется в model.User.Conflicts.
+func NewArbiter(strategy string, weights map[string]float64, sources ...Enricher) (Enricher, error) {
+	if len(sources) == 0 {
+		return nil, errors.New("arbiter: no sources")
+	}
+	switch strategy {
+	case StrategyPriority, StrategyConfidence, StrategyVote:
+	default:
+		return nil, fmt.Errorf("arbiter: unknown strategy %q", strategy)
+	}
+
+	fields := sources[0].Fields()
+	if len(fields) != 1 {
+		return nil, fmt.Errorf("arbiter: %s fills %d fields, wanted 1", sources[0].Name(), len(fields))
+	}
+	for _, s := range sources[1:] {
+		if !slices.Equal(s.Fields(), fields) {
+			return nil, fmt.Errorf("arbiter: %s and %s fill different fields", sources[0].Name(), s.Name())
+		}
+	}
+
+	return &arbiter{
+		strategy: strategy,
+		weights:  weights,
+		sources:  sources,
+		field:    fields[0],
+	}, nil
+}
+
+type arbiter struct {
+	strategy string
+	weights  map[string]float64
+	sources  []Enricher
+	field    string
+}
+
+func (a *arbiter) Name() string {
+	return a.sources[0].Name()
+}
+
+func (a *arbiter) Fields() []string {
+	return []string{a.field}
+}
+
+// Requires возвращает поля, нужные любому из источников.
+func (a *arbiter) Requires() []string {
+	var fields []string
+	for _, s := range a.sources {
+		fields = append(fields, requires(s)...)
+	}
+	slices.Sort(fields)
+	return slices.Compact(fields)
+}
+
+// Provider возвращает источники в порядке приоритета.
+func (a *arbiter) Provider() string {
+	names := make([]string, len(a.sources))
+	for i, s := range a.sources {
+		names[i] = providerName(s)
+	}
+	return strings.Join(names, ",")
+}
+
+func (a *arbiter) Enrich(ctx context.Context, user *model.User) error {
+	type result struct {
+		user model.User
+		err  error
+	}
+
+	results := make([]result, len(a.sources))
+	done := make(chan struct{}, len(a.sources))
+	for i, s := range a.sources {
+		results[i].user = *user
+		results[i].user.Provenance = maps.Clone(user.Provenance)
+		results[i].user.Conflicts = nil
+		go func() {
+			results[i].err = s.Enrich(ctx, &results[i].user)
+			done <- struct{}{}
+		}()
+	}
+	for range a.sources {
+		<-done
+	}
+
+	var (
+		candidates []model.Candidate
+		winners    []int // номер источника для каждого кандидата
+		errs       []error
+	)
+	for i, res := range results {
+		if res.err != nil {
+			if !errors.Is(res.err, ErrNoData) {
+				errs = append(errs, fmt.Errorf("%s: %w", providerName(a.sources[i]), res.err))
+			}
+			continue
+		}
+
+		value, probability := candidateValue(&res.user, a.field)
+		candidates = append(candidates, model.Candidate{
+			Source:      providerName(a.sources[i]),
+			Value:       value,
+			Probability: probability,
+		})
+		winners = append(winners, i)
+	}
+
+	if len(candidates) == 0 {
+		if len(errs) > 0 {
+			return errors.Join(errs...)
+		}
+		return ErrNoData
+	}
+
+	chosen := a.choose(candidates)
+	copyFields(user, &results[winners[chosen]].user, a.Fields())
+
+	if disagree(candidates) {
+		user.Conflicts = append(user.Conflicts, model.EnrichmentConflict{
+			Field:      a.field,
+			Strategy:   a.strategy,
+			Chosen:     candidates[chosen].Value,
+			Candidates: candidates,
+			CreatedAt:  time.Now(),
+		})
+	}
+	return nil
+}
+
+// choose возвращает номер выбранного кандидата. Кандидаты идут в порядке приоритета источников.
+func (a *arbiter) choose(candidates []model.Candidate) int {
+	switch a.strategy {
+	case StrategyConfidence:
+		best := 0
+		for i, c := range candidates {
+			if c.Probability > candidates[best].Probability {
+				best = i
+			}
+		}
+		return best
+
+	case StrategyVote:
+		scores := make(map[string]float64)
+		for _, c := range candidates {
+			weight, ok := a.weights[c.Source]
+			if !ok {
+				weight = 1
+			}
+			scores[c.Value] += weight * c.Probability
+		}
+
+		// при равенстве голосов побеждает значение более приоритетного источника
+		best := 0
+		for i, c := range candidates {
+			if scores[c.Value] > scores[candidates[best].Value] {
+				best = i
+			}
+		}
+		return best
+
+	default:
+		return 0
+	}
+}
+
+// disagree сообщает, что кандидаты предлагают разные значения.
+func disagree(candidates []model.Candidate) bool {
+	for _, c := range candidates[1:] {
+		if c.Value != candidates[0].Value {
+			return true
+		}
+	}
+	return false
+}
+
+// candidateValue возвращает значение поля field пользователя и уверенность источника в нём.
+// Для возраста уверенность не сообщается и считается равной 1.
+func candidateValue(user *model.User, field string) (string, float64) {
+	switch field {
+	case "age":
+		return strconv.Itoa(user.Age), 1
+	case "gender":
+		return user.Gender, user.GenderProbability
+	case "nationality":
+		return user.Nationality, user.NationalityProbability
+	}
+	return "", 0
+}
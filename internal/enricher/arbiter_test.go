@@ -0,0 +1,90 @@
+package enricher
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aachex/service/internal/model"
+)
+
+// genderSource предлагает пол gender с уверенностью probability.
+type genderSource struct {
+	provider    string
+	gender      string
+	probability float64
+	err         error
+}
+
+func (s *genderSource) Name() string     { return "gender" }
+func (s *genderSource) Fields() []string { return []string{"gender"} }
+func (s *genderSource) Provider() string { return s.provider }
+
+func (s *genderSource) Enrich(ctx context.Context, user *model.User) error {
+	if s.err != nil {
+		return s.err
+	}
+	user.Gender = s.gender
+	user.GenderProbability = s.probability
+	return nil
+}
+
+func TestArbiter(t *testing.T) {
+	dictionary := &genderSource{provider: "dictionary", gender: "female", probability: 0.6}
+	genderize := &genderSource{provider: "genderize", gender: "male", probability: 0.9}
+	other := &genderSource{provider: "other", gender: "female", probability: 0.5}
+
+	tests := []struct {
+		strategy string
+		weights  map[string]float64
+		want     string
+	}{
+		{StrategyPriority, nil, "female"},
+		{StrategyConfidence, nil, "male"},
+		{StrategyVote, nil, "female"},                              // 0.6 + 0.5 против 0.9
+		{StrategyVote, map[string]float64{"genderize": 2}, "male"}, // 1.1 против 1.8
+	}
+
+	for _, tt := range tests {
+		a, err := NewArbiter(tt.strategy, tt.weights, dictionary, genderize, other)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		u := model.User{Name: "Sasha"}
+		if err = a.Enrich(t.Context(), &u); err != nil {
+			t.Fatal(err)
+		}
+
+		if u.Gender != tt.want {
+			t.Errorf("%s %v: wanted %s, got %s", tt.strategy, tt.weights, tt.want, u.Gender)
+		}
+		if len(u.Conflicts) != 1 || u.Conflicts[0].Chosen != tt.want || len(u.Conflicts[0].Candidates) != 3 {
+			t.Errorf("%s %v: conflict wasn't recorded: %+v", tt.strategy, tt.weights, u.Conflicts)
+		}
+	}
+}
+
+func TestArbiterAgreementAndErrors(t *testing.T) {
+	down := &genderSource{provider: "genderize", err: errors.New("provider is down")}
+	unknown := &genderSource{provider: "dictionary", err: ErrNoData}
+	male := &genderSource{provider: "other", gender: "male", probability: 0.8}
+
+	// согласие источников - не расхождение, ошибка одного из них не мешает выбору
+	a, _ := NewArbiter(StrategyConfidence, nil, down, male, &genderSource{provider: "x", gender: "male", probability: 0.7})
+	u := model.User{}
+	if err := a.Enrich(t.Context(), &u); err != nil || u.Gender != "male" || len(u.Conflicts) != 0 {
+		t.Errorf("unexpected result %+v (%v)", u, err)
+	}
+
+	// без данных и со сбоем итог - сбой, а не отсутствие данных
+	a, _ = NewArbiter(StrategyPriority, nil, unknown, down)
+	report := NewReport(a, a.Enrich(t.Context(), &model.User{}))
+	if o := report["gender"]; o.Status != StatusProviderError || o.Provider != "dictionary,genderize" {
+		t.Errorf("unexpected outcome %+v", o)
+	}
+
+	if _, err := NewArbiter("random", nil, male); err == nil {
+		t.Error("unknown strategy was accepted")
+	}
+}
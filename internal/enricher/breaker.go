@@ -0,0 +1,350 @@
+package enricher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrProviderUnavailable возвращается без обращения к провайдеру, пока его автомат защиты разомкнут.
+	ErrProviderUnavailable = errors.New("provider unavailable")
+
+	// ErrRateLimited возвращается, когда провайдер ответил 429 Too Many Requests.
+	ErrRateLimited = errors.New("rate limited")
+)
+
+// Состояния автомата защиты провайдера.
+const (
+	BreakerClosed   = "closed"    // запросы к провайдеру разрешены
+	BreakerOpen     = "open"      // провайдер пропускается до истечения open_until
+	BreakerHalfOpen = "half_open" // разрешён один пробный запрос
+)
+
+// Quota - суточная квота запросов к провайдеру.
+type Quota struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+// QuotaStore сохраняет квоты провайдеров между перезапусками сервиса и делит их между его экземплярами.
+type QuotaStore interface {
+	LoadQuota(ctx context.Context, provider string) (quota Quota, ok bool, err error)
+	SaveQuota(ctx context.Context, provider string, quota Quota) error
+}
+
+// BreakerConfig - настройки автоматов защиты провайдеров.
+type BreakerConfig struct {
+	Threshold int           // количество ошибок подряд, после которого провайдер отключается
+	Cooldown  time.Duration // на сколько отключается провайдер после Threshold ошибок
+	Store     QuotaStore    // может быть nil, тогда квота хранится только в памяти
+}
+
+// BreakerStatus - состояние автомата защиты провайдера для операторов.
+type BreakerStatus struct {
+	Provider  string     `json:"provider"`
+	State     string     `json:"state"`
+	Reason    string     `json:"reason,omitempty"`
+	Failures  int        `json:"failures"`
+	OpenUntil *time.Time `json:"open_until,omitempty"`
+	Quota     *Quota     `json:"quota,omitempty"`
+}
+
+// Breakers возвращает автоматы защиты обогатителей, у которых они включены.
+func Breakers(enrichers []Enricher) []*Breaker {
+	var breakers []*Breaker
+	for _, e := range enrichers {
+		if b, ok := e.(interface{ Breaker() *Breaker }); ok && b.Breaker() != nil {
+			breakers = append(breakers, b.Breaker())
+		}
+	}
+	return breakers
+}
+
+// Breaker отключает обращения к провайдеру, когда тот исчерпал квоту или раз за разом отвечает ошибками,
+// и снова пропускает их, когда квота обновилась или прошло время остывания.
+type Breaker struct {
+	provider   string
+	cfg        BreakerConfig
+	dailyQuota int // известная заранее суточная квота; 0, если квота узнаётся только из заголовков ответа
+
+	mu        sync.Mutex
+	loaded    bool
+	state     string
+	reason    string
+	failures  int
+	openUntil time.Time
+	trial     bool // пробный запрос в полуоткрытом состоянии уже выполняется
+	quota     Quota
+	dirty     bool // квота изменилась и ещё не сохранена
+	saving    bool // квота сохраняется в хранилище
+
+	now func() time.Time
+}
+
+// NewBreaker создаёт автомат защиты провайдера provider. dailyQuota - суточная квота провайдера,
+// если она известна заранее.
+func NewBreaker(provider string, dailyQuota int, cfg BreakerConfig) *Breaker {
+	return &Breaker{
+		provider:   provider,
+		cfg:        cfg,
+		dailyQuota: dailyQuota,
+		state:      BreakerClosed,
+		now:        time.Now,
+	}
+}
+
+// Allow возвращает ErrProviderUnavailable, если обращаться к провайдеру сейчас нельзя.
+// Если обращаться можно, из квоты резервируется один запрос, и квота сохраняется в хранилище,
+// чтобы после перезапуска сервиса не начинать её заново.
+func (b *Breaker) Allow(ctx context.Context) error {
+	defer b.persist(ctx)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.load(ctx)
+	now := b.now()
+
+	if b.quotaKnown() && !now.Before(b.quota.ResetAt) {
+		b.resetQuota(now)
+		b.dirty = true
+	}
+	if b.quotaKnown() && b.quota.Remaining <= 0 {
+		return b.unavailable("quota exhausted", b.quota.ResetAt)
+	}
+
+	switch b.state {
+	case BreakerOpen:
+		if now.Before(b.openUntil) {
+			return b.unavailable(b.reason, b.openUntil)
+		}
+		b.state = BreakerHalfOpen
+		b.trial = false
+		fallthrough
+
+	case BreakerHalfOpen:
+		if b.trial {
+			return b.unavailable(b.reason, b.openUntil)
+		}
+		b.trial = true
+	}
+
+	if b.quotaKnown() {
+		b.quota.Remaining--
+		b.dirty = true
+	}
+	return nil
+}
+
+// Cancel учитывает запрос, прерванный вызывающей стороной. Такой запрос не говорит о состоянии провайдера.
+func (b *Breaker) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+}
+
+// Success учитывает успешный ответ провайдера. quota - квота из заголовков ответа, если они были.
+func (b *Breaker) Success(ctx context.Context, quota *Quota) {
+	defer b.persist(ctx)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = BreakerClosed
+	b.reason = ""
+	b.failures = 0
+	b.trial = false
+	b.updateQuota(quota)
+}
+
+// Failure учитывает ошибку обращения к провайдеру. quota - квота из заголовков ответа, если они были.
+// Ответ 429 размыкает автомат до сброса квоты, остальные ошибки - после Threshold ошибок подряд.
+func (b *Breaker) Failure(ctx context.Context, err error, quota *Quota) {
+	defer b.persist(ctx)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	now := b.now()
+
+	if errors.Is(err, ErrRateLimited) {
+		if quota == nil {
+			quota = &Quota{Limit: b.quota.Limit, ResetAt: nextDay(now)}
+		}
+		quota.Remaining = 0
+		b.updateQuota(quota)
+		b.open("rate limited", quota.ResetAt)
+		return
+	}
+
+	b.updateQuota(quota)
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= max(b.cfg.Threshold, 1) {
+		b.open(err.Error(), now.Add(b.cfg.Cooldown))
+	}
+}
+
+// Status возвращает текущее состояние автомата.
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		Provider: b.provider,
+		State:    b.state,
+		Reason:   b.reason,
+		Failures: b.failures,
+	}
+	if b.state != BreakerClosed {
+		openUntil := b.openUntil
+		status.OpenUntil = &openUntil
+	}
+	if b.quotaKnown() {
+		quota := b.quota
+		status.Quota = &quota
+	}
+	return status
+}
+
+func (b *Breaker) unavailable(reason string, until time.Time) error {
+	return fmt.Errorf("%w: %s: %s until %s", ErrProviderUnavailable, b.provider, reason, until.Format(time.RFC3339))
+}
+
+// quotaKnown сообщает, известна ли квота провайдера. Вызывается под b.mu.
+func (b *Breaker) quotaKnown() bool {
+	return !b.quota.ResetAt.IsZero()
+}
+
+// open размыкает автомат до момента until. Вызывается под b.mu.
+func (b *Breaker) open(reason string, until time.Time) {
+	b.state = BreakerOpen
+	b.reason = reason
+	b.openUntil = until
+}
+
+// load однократно загружает сохранённую квоту. Вызывается под b.mu.
+// Квота загружается до первого обращения к провайдеру, поэтому его ждут все запросы.
+func (b *Breaker) load(ctx context.Context) {
+	if b.loaded {
+		return
+	}
+	b.loaded = true
+
+	if b.dailyQuota > 0 {
+		b.resetQuota(b.now())
+	}
+	if b.cfg.Store == nil {
+		return
+	}
+
+	quota, ok, err := b.cfg.Store.LoadQuota(ctx, b.provider)
+	if err == nil && ok {
+		b.mergeQuota(quota)
+	}
+}
+
+// mergeQuota учитывает квоту stored из хранилища. Ту же квоту тратят другие экземпляры сервиса,
+// поэтому в пределах одних суток остаётся меньший из остатков, а квота более поздних суток заменяет текущую.
+// Вызывается под b.mu.
+func (b *Breaker) mergeQuota(stored Quota) {
+	if b.quotaKnown() && stored.ResetAt.Equal(b.quota.ResetAt) {
+		b.quota.Remaining = min(b.quota.Remaining, stored.Remaining)
+		return
+	}
+	if stored.ResetAt.After(b.quota.ResetAt) {
+		b.quota = stored
+	}
+}
+
+// resetQuota начинает новые сутки квоты. Вызывается под b.mu.
+func (b *Breaker) resetQuota(now time.Time) {
+	if b.dailyQuota > 0 {
+		b.quota = Quota{Limit: b.dailyQuota, Remaining: b.dailyQuota, ResetAt: nextDay(now)}
+	} else {
+		b.quota = Quota{}
+	}
+}
+
+// updateQuota запоминает квоту из ответа провайдера для сохранения в хранилище. Вызывается под b.mu.
+func (b *Breaker) updateQuota(quota *Quota) {
+	if quota == nil {
+		return
+	}
+	if quota.Limit == 0 {
+		quota.Limit = max(b.quota.Limit, b.dailyQuota)
+	}
+
+	b.quota = *quota
+	b.dirty = true
+}
+
+// persist сохраняет изменившуюся квоту в хранилище и перечитывает её, чтобы учесть расход других экземпляров.
+// Вызывается без b.mu: обращения к провайдеру не ждут хранилище. Если квоту уже сохраняет другой вызов,
+// он сохранит и это изменение, поэтому одновременно идёт не больше одного сохранения.
+func (b *Breaker) persist(ctx context.Context) {
+	if b.cfg.Store == nil {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.saving {
+		return
+	}
+
+	b.saving = true
+	for b.dirty {
+		b.dirty = false
+		quota := b.quota
+
+		b.mu.Unlock()
+		err := b.cfg.Store.SaveQuota(ctx, b.provider, quota)
+		var (
+			stored Quota
+			ok     bool
+		)
+		if err == nil {
+			stored, ok, err = b.cfg.Store.LoadQuota(ctx, b.provider)
+		}
+		b.mu.Lock()
+
+		if err != nil {
+			// хранилище недоступно: квота сохранится со следующим изменением
+			b.dirty = true
+			break
+		}
+		if ok {
+			b.mergeQuota(stored)
+		}
+	}
+	b.saving = false
+}
+
+// quotaFromHeaders читает квоту из заголовков X-Rate-Limit-*. Возвращает nil, если заголовков нет.
+func quotaFromHeaders(h http.Header, now time.Time) *Quota {
+	remaining, err := strconv.Atoi(h.Get("X-Rate-Limit-Remaining"))
+	if err != nil {
+		return nil
+	}
+
+	quota := &Quota{Remaining: remaining, ResetAt: nextDay(now)}
+	if limit, err := strconv.Atoi(h.Get("X-Rate-Limit-Limit")); err == nil {
+		quota.Limit = limit
+	}
+	// X-Rate-Limit-Reset - количество секунд до сброса квоты
+	if reset, err := strconv.Atoi(h.Get("X-Rate-Limit-Reset")); err == nil {
+		quota.ResetAt = now.Add(time.Duration(reset) * time.Second)
+	}
+	return quota
+}
+
+// nextDay возвращает начало следующих суток по UTC.
+func nextDay(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
@@ -0,0 +1,272 @@
+package enricher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aachex/service/internal/model"
+)
+
+func TestBreakerOpensAfterFailures(t *testing.T) {
+	now := time.Now()
+	b := NewBreaker("agify", 0, BreakerConfig{Threshold: 2, Cooldown: time.Minute})
+	b.now = func() time.Time { return now }
+
+	for range 2 {
+		if err := b.Allow(t.Context()); err != nil {
+			t.Fatal(err)
+		}
+		b.Failure(t.Context(), errors.New("bad gateway"), nil)
+	}
+
+	if err := b.Allow(t.Context()); !errors.Is(err, ErrProviderUnavailable) {
+		t.Fatalf("wanted ErrProviderUnavailable, got %v", err)
+	}
+	if s := b.Status(); s.State != BreakerOpen || s.Failures != 2 {
+		t.Errorf("unexpected status %+v", s)
+	}
+
+	// после остывания пропускается только один пробный запрос
+	now = now.Add(2 * time.Minute)
+	if err := b.Allow(t.Context()); err != nil {
+		t.Fatalf("trial request wasn't allowed: %v", err)
+	}
+	if err := b.Allow(t.Context()); !errors.Is(err, ErrProviderUnavailable) {
+		t.Fatalf("second request in half-open state was allowed")
+	}
+
+	b.Success(t.Context(), nil)
+	if s := b.Status(); s.State != BreakerClosed || s.Failures != 0 {
+		t.Errorf("breaker wasn't closed after success: %+v", s)
+	}
+}
+
+func TestBreakerDailyQuota(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	b := NewBreaker("genderize", 2, BreakerConfig{Threshold: 5})
+	b.now = func() time.Time { return now }
+
+	for range 2 {
+		if err := b.Allow(t.Context()); err != nil {
+			t.Fatal(err)
+		}
+		b.Success(t.Context(), nil)
+	}
+
+	if err := b.Allow(t.Context()); !errors.Is(err, ErrProviderUnavailable) {
+		t.Fatalf("wanted quota to be exhausted, got %v", err)
+	}
+
+	// квота восстанавливается в начале следующих суток
+	now = now.Add(12 * time.Hour)
+	if err := b.Allow(t.Context()); err != nil {
+		t.Fatalf("quota wasn't reset: %v", err)
+	}
+	if q := b.Status().Quota; q == nil || q.Limit != 2 || q.Remaining != 1 {
+		t.Errorf("unexpected quota %+v", q)
+	}
+}
+
+func TestBreakerDailyQuotaPersisted(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	store := memoryQuotaStore{}
+
+	b := NewBreaker("genderize", 2, BreakerConfig{Threshold: 5, Store: store})
+	b.now = func() time.Time { return now }
+	if err := b.Allow(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if q := store["genderize"]; q.Limit != 2 || q.Remaining != 1 {
+		t.Fatalf("used quota wasn't persisted: %+v", q)
+	}
+
+	// после перезапуска квота продолжается с сохранённого остатка
+	b = NewBreaker("genderize", 2, BreakerConfig{Threshold: 5, Store: store})
+	b.now = func() time.Time { return now }
+	if err := b.Allow(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Allow(t.Context()); !errors.Is(err, ErrProviderUnavailable) {
+		t.Errorf("quota was reset by restart, got %v", err)
+	}
+}
+
+func TestBreakerQuotaSavedOutsideLock(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	store := &blockingQuotaStore{saving: make(chan struct{}), release: make(chan struct{})}
+
+	b := NewBreaker("genderize", 10, BreakerConfig{Threshold: 5, Store: store})
+	b.now = func() time.Time { return now }
+
+	first := make(chan error)
+	go func() { first <- b.Allow(t.Context()) }()
+	<-store.saving
+
+	// пока первый запрос сохраняет квоту, остальные к провайдеру не ждут хранилище
+	done := make(chan error)
+	go func() { done <- b.Allow(t.Context()) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Allow waited for the quota store")
+	}
+
+	close(store.release)
+	if err := <-first; err != nil {
+		t.Fatal(err)
+	}
+	if q := store.get(); q.Remaining != 8 || store.saves != 2 {
+		t.Errorf("wanted both requests saved in 2 calls, got %+v after %d saves", q, store.saves)
+	}
+}
+
+func TestBreakerQuotaShared(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	store := memoryQuotaStore{}
+
+	// другой экземпляр сервиса уже потратил почти всю квоту
+	store["genderize"] = Quota{Limit: 10, Remaining: 1, ResetAt: nextDay(now)}
+
+	b := NewBreaker("genderize", 10, BreakerConfig{Threshold: 5, Store: store})
+	b.now = func() time.Time { return now }
+	if err := b.Allow(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Allow(t.Context()); !errors.Is(err, ErrProviderUnavailable) {
+		t.Errorf("quota spent by another instance was ignored, got %v", err)
+	}
+
+	// квота прошлых суток не загружается
+	store["genderize"] = Quota{Limit: 10, Remaining: 0, ResetAt: now.Add(-time.Hour)}
+	b = NewBreaker("genderize", 10, BreakerConfig{Threshold: 5, Store: store})
+	b.now = func() time.Time { return now }
+	if err := b.Allow(t.Context()); err != nil {
+		t.Errorf("stale quota was loaded: %v", err)
+	}
+}
+
+func TestProviderRateLimited(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-Rate-Limit-Limit", "100")
+		w.Header().Set("X-Rate-Limit-Remaining", "0")
+		w.Header().Set("X-Rate-Limit-Reset", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":"Request limit reached"}`))
+	}))
+	defer srv.Close()
+
+	store := memoryQuotaStore{}
+	e := NewGenderEnricher(ProviderConfig{BaseURL: srv.URL}, WithBreaker(BreakerConfig{Threshold: 5, Store: store}))
+
+	u := model.User{Name: "Ivan"}
+	if err := e.Enrich(t.Context(), &u); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("wanted ErrRateLimited, got %v", err)
+	}
+	if err := e.Enrich(t.Context(), &u); !errors.Is(err, ErrProviderUnavailable) {
+		t.Fatalf("wanted ErrProviderUnavailable, got %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("provider was called %d times while its quota was exhausted", calls)
+	}
+	if u.Gender != "" {
+		t.Errorf("error response was used as gender %q", u.Gender)
+	}
+	if q, ok := store["genderize"]; !ok || q.Limit != 100 || q.Remaining != 0 {
+		t.Errorf("quota wasn't persisted: %+v", q)
+	}
+}
+
+func TestProviderHangs(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	e := NewGenderEnricher(ProviderConfig{BaseURL: srv.URL}, WithBreaker(BreakerConfig{Threshold: 1, Cooldown: time.Minute}))
+
+	// срок истекает у вызывающей стороны, как при ENRICH_TIMEOUT
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	u := model.User{Name: "Ivan"}
+	if err := e.Enrich(ctx, &u); err == nil {
+		t.Fatal("wanted an error from a provider that hangs")
+	}
+	if s := e.Breaker().Status(); s.State != BreakerOpen || s.Failures != 1 {
+		t.Errorf("hanging provider wasn't counted as a failure: %+v", s)
+	}
+
+	// отмена запроса вызывающей стороной ошибкой провайдера не считается
+	e = NewGenderEnricher(ProviderConfig{BaseURL: srv.URL}, WithBreaker(BreakerConfig{Threshold: 1, Cooldown: time.Minute}))
+	ctx, cancel = context.WithCancel(t.Context())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	e.Enrich(ctx, &u)
+	if s := e.Breaker().Status(); s.State != BreakerClosed || s.Failures != 0 {
+		t.Errorf("canceled request was counted as a failure: %+v", s)
+	}
+}
+
+type memoryQuotaStore map[string]Quota
+
+func (s memoryQuotaStore) LoadQuota(_ context.Context, provider string) (Quota, bool, error) {
+	q, ok := s[provider]
+	return q, ok, nil
+}
+
+func (s memoryQuotaStore) SaveQuota(_ context.Context, provider string, quota Quota) error {
+	s[provider] = quota
+	return nil
+}
+
+// blockingQuotaStore сообщает о первом сохранении квоты в saving и не завершает его до закрытия release.
+type blockingQuotaStore struct {
+	saving  chan struct{}
+	release chan struct{}
+
+	mu    sync.Mutex
+	quota Quota
+	saves int
+}
+
+func (s *blockingQuotaStore) LoadQuota(_ context.Context, _ string) (Quota, bool, error) {
+	q := s.get()
+	return q, !q.ResetAt.IsZero(), nil
+}
+
+func (s *blockingQuotaStore) SaveQuota(_ context.Context, _ string, quota Quota) error {
+	s.mu.Lock()
+	s.saves++
+	first := s.saves == 1
+	s.mu.Unlock()
+
+	if first {
+		close(s.saving)
+		<-s.release
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quota = quota
+	return nil
+}
+
+func (s *blockingQuotaStore) get() Quota {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.quota
+}
@@ -0,0 +1,172 @@
+package enricher
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheStore - постоянное хранилище кэша, общее для нескольких экземпляров сервиса.
+type CacheStore interface {
+	// GetCached возвращает значение по ключу, если срок его хранения ещё не истёк.
+	GetCached(ctx context.Context, key string) (value []byte, expiresAt time.Time, ok bool, err error)
+
+	// SetCached сохраняет значение по ключу до момента expiresAt.
+	SetCached(ctx context.Context, key string, value []byte, expiresAt time.Time) error
+}
+
+// CacheStats - статистика обращений к кэшу.
+type CacheStats struct {
+	Hits         int64 `json:"hits"`
+	NegativeHits int64 `json:"negative_hits"` // попадания в записи об отсутствии данных, входят в Hits
+	StoreHits    int64 `json:"store_hits"`    // попадания в постоянное хранилище, входят в Hits
+	Misses       int64 `json:"misses"`
+	Size         int   `json:"size"` // количество записей в памяти
+}
+
+// Cache кэширует ответы внешних API: в памяти с вытеснением давно не использованных записей
+// и, если задано постоянное хранилище, в нём.
+//
+// Пустое значение означает, что у провайдера нет данных. Такие записи хранятся negativeTTL,
+// чтобы повторно спросить провайдера раньше, чем для обычных записей.
+type Cache struct {
+	capacity    int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	store       CacheStore
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	recent  *list.List // в начале - последние использованные записи
+	stats   CacheStats
+
+	now func() time.Time
+}
+
+type cacheEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewCache создаёт кэш на capacity записей в памяти. store может быть nil.
+func NewCache(capacity int, ttl, negativeTTL time.Duration, store CacheStore) *Cache {
+	return &Cache{
+		capacity:    capacity,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		store:       store,
+		entries:     make(map[string]*list.Element),
+		recent:      list.New(),
+		now:         time.Now,
+	}
+}
+
+// Get возвращает значение по ключу и true, если оно есть в кэше и не устарело.
+// Ошибки постоянного хранилища считаются промахом.
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, bool) {
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*cacheEntry)
+		if c.now().Before(entry.expiresAt) {
+			c.recent.MoveToFront(el)
+			c.hit(entry.value, false)
+			c.mu.Unlock()
+			return entry.value, true
+		}
+
+		c.recent.Remove(el)
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+
+	if c.store != nil {
+		value, expiresAt, ok, err := c.store.GetCached(ctx, key)
+		if err == nil && ok {
+			c.mu.Lock()
+			c.put(key, value, expiresAt)
+			c.hit(value, true)
+			c.mu.Unlock()
+			return value, true
+		}
+	}
+
+	c.mu.Lock()
+	c.stats.Misses++
+	c.mu.Unlock()
+	return nil, false
+}
+
+// Set сохраняет значение по ключу. Пустое значение сохраняется как запись об отсутствии данных.
+func (c *Cache) Set(ctx context.Context, key string, value []byte) {
+	ttl := c.ttl
+	if len(value) == 0 {
+		ttl = c.negativeTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+	expiresAt := c.now().Add(ttl)
+
+	c.mu.Lock()
+	c.put(key, value, expiresAt)
+	c.mu.Unlock()
+
+	if c.store != nil {
+		c.store.SetCached(ctx, key, value, expiresAt)
+	}
+}
+
+// Stats возвращает статистику обращений к кэшу.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = c.recent.Len()
+	return stats
+}
+
+// put добавляет запись в память, вытесняя самую давно использованную при переполнении.
+// Вызывается под c.mu.
+func (c *Cache) put(key string, value []byte, expiresAt time.Time) {
+	if c.capacity <= 0 {
+		return
+	}
+
+	if el, ok := c.entries[key]; ok {
+		el.Value = &cacheEntry{key: key, value: value, expiresAt: expiresAt}
+		c.recent.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.recent.PushFront(&cacheEntry{key: key, value: value, expiresAt: expiresAt})
+	if c.recent.Len() > c.capacity {
+		oldest := c.recent.Back()
+		c.recent.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// hit учитывает попадание в кэш. Вызывается под c.mu.
+func (c *Cache) hit(value []byte, fromStore bool) {
+	c.stats.Hits++
+	if len(value) == 0 {
+		c.stats.NegativeHits++
+	}
+	if fromStore {
+		c.stats.StoreHits++
+	}
+}
+
+// cacheKey возвращает ключ кэша для ответа провайдера по имени среди жителей страны country.
+// Имя приводится к нижнему регистру, лишние пробелы убираются.
+func cacheKey(provider, name, country string) string {
+	key := provider + ":" + strings.Join(strings.Fields(strings.ToLower(name)), " ")
+	if country != "" {
+		key += ":" + strings.ToUpper(country)
+	}
+	return key
+}
@@ -0,0 +1,98 @@
+package enricher
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// memoryStore - постоянное хранилище кэша в памяти.
+type memoryStore map[string]cacheEntry
+
+func (s memoryStore) GetCached(_ context.Context, key string) ([]byte, time.Time, bool, error) {
+	e, ok := s[key]
+	return e.value, e.expiresAt, ok, nil
+}
+
+func (s memoryStore) SetCached(_ context.Context, key string, value []byte, expiresAt time.Time) error {
+	s[key] = cacheEntry{key: key, value: value, expiresAt: expiresAt}
+	return nil
+}
+
+func TestCacheEviction(t *testing.T) {
+	c := NewCache(2, time.Hour, time.Minute, nil)
+
+	c.Set(t.Context(), "a", []byte("1"))
+	c.Set(t.Context(), "b", []byte("2"))
+	c.Get(t.Context(), "a") // a становится последней использованной
+	c.Set(t.Context(), "c", []byte("3"))
+
+	if _, ok := c.Get(t.Context(), "b"); ok {
+		t.Error("least recently used entry wasn't evicted")
+	}
+	if v, ok := c.Get(t.Context(), "a"); !ok || string(v) != "1" {
+		t.Error("recently used entry was evicted")
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 || stats.Size != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	now := time.Now()
+	c := NewCache(10, time.Hour, time.Minute, nil)
+	c.now = func() time.Time { return now }
+
+	c.Set(t.Context(), "known", []byte(`{"age":40}`))
+	c.Set(t.Context(), "unknown", nil)
+
+	if v, ok := c.Get(t.Context(), "unknown"); !ok || len(v) != 0 {
+		t.Error("negative entry wasn't cached")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.Get(t.Context(), "unknown"); ok {
+		t.Error("negative entry outlived negative ttl")
+	}
+	if _, ok := c.Get(t.Context(), "known"); !ok {
+		t.Error("entry expired before ttl")
+	}
+
+	now = now.Add(time.Hour)
+	if _, ok := c.Get(t.Context(), "known"); ok {
+		t.Error("entry outlived ttl")
+	}
+
+	if stats := c.Stats(); stats.NegativeHits != 1 {
+		t.Errorf("wanted 1 negative hit, got %d", stats.NegativeHits)
+	}
+}
+
+func TestCacheStore(t *testing.T) {
+	store := memoryStore{}
+
+	NewCache(10, time.Hour, time.Minute, store).Set(t.Context(), "k", []byte("v"))
+
+	// новый экземпляр с пустой памятью находит запись в хранилище
+	c := NewCache(10, time.Hour, time.Minute, store)
+	if v, ok := c.Get(t.Context(), "k"); !ok || string(v) != "v" {
+		t.Fatal("entry wasn't loaded from store")
+	}
+	if stats := c.Stats(); stats.StoreHits != 1 || stats.Size != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	if cacheKey("agify", "  Ivan ", "") != cacheKey("agify", "ivan", "") {
+		t.Error("names that differ only in case and spaces must share a key")
+	}
+	if cacheKey("agify", "ivan", "") == cacheKey("genderize", "ivan", "") {
+		t.Error("providers must not share keys")
+	}
+	if cacheKey("agify", "ivan", "RU") == cacheKey("agify", "ivan", "") {
+		t.Error("localized and global answers must not share keys")
+	}
+}
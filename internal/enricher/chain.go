@@ -0,0 +1,300 @@
+package enricher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aachex/service/internal/model"
+)
+
+// Chain параллельно применяет обогатители к пользователю.
+type Chain struct {
+	enrichers []Enricher
+	budget    time.Duration
+}
+
+// NewChain создаёт цепочку обогатителей. Если budget больше нуля, обогащение
+// прерывается по его истечении, и в пользователя попадают только успевшие результаты.
+func NewChain(budget time.Duration, enrichers ...Enricher) *Chain {
+	return &Chain{
+		enrichers: enrichers,
+		budget:    budget,
+	}
+}
+
+func (c *Chain) Name() string {
+	names := make([]string, 0, len(c.enrichers))
+	for _, e := range c.enrichers {
+		names = append(names, e.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+func (c *Chain) Fields() []string {
+	var fields []string
+	for _, e := range c.enrichers {
+		fields = append(fields, e.Fields()...)
+	}
+	return fields
+}
+
+// Enrich запускает обогатители одновременно, каждый над своей копией пользователя, и переносит в user
+// поля тех, что завершились без ошибки до отмены ctx или истечения бюджета. Обогатитель, которому нужны
+// поля других обогатителей цепочки (см. Dependent), запускается после того, как они завершатся.
+// Обогатители заблокированных полей не запускаются и завершаются ошибкой ErrLocked.
+// Ошибки обогатителей оборачиваются в EnricherError и объединяются в одну.
+func (c *Chain) Enrich(ctx context.Context, user *model.User) error {
+	if c.budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.budget)
+		defer cancel()
+	}
+
+	type result struct {
+		idx  int
+		user model.User
+		err  error
+	}
+
+	deps := c.dependencies()
+	done := make([]chan struct{}, len(c.enrichers))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	// mu защищает user: обогатители копируют его, дождавшись зависимостей, пока результаты переносятся в него.
+	// После выхода из Enrich опоздавшие обогатители user не читают.
+	var mu sync.Mutex
+	finished := false
+	defer func() {
+		mu.Lock()
+		finished = true
+		mu.Unlock()
+	}()
+
+	// буфер нужен, чтобы опоздавшие обогатители не блокировались после выхода из Enrich
+	results := make(chan result, len(c.enrichers))
+	for i, e := range c.enrichers {
+		go func() {
+			for _, d := range deps[i] {
+				select {
+				case <-done[d]:
+				case <-ctx.Done():
+					results <- result{idx: i, err: ctx.Err()}
+					return
+				}
+			}
+
+			mu.Lock()
+			if finished {
+				mu.Unlock()
+				return
+			}
+			res := result{idx: i, user: *user}
+			res.user.Provenance = maps.Clone(user.Provenance)
+			res.user.Conflicts = nil
+			mu.Unlock()
+
+			if field, ok := lockedField(&res.user, e.Fields()); ok {
+				res.err = fmt.Errorf("%w: %s set by %s", ErrLocked, field, res.user.Provenance[field].Source)
+			} else {
+				res.err = e.Enrich(ctx, &res.user)
+			}
+			results <- res
+		}()
+	}
+
+	var errs []error
+	for pending := len(c.enrichers); pending > 0; pending-- {
+		select {
+		case res := <-results:
+			e := c.enrichers[res.idx]
+			if res.err != nil {
+				errs = append(errs, &EnricherError{Enricher: e, Err: res.err})
+			} else {
+				mu.Lock()
+				copyFields(user, &res.user, e.Fields())
+				mu.Unlock()
+			}
+			close(done[res.idx])
+
+		case <-ctx.Done():
+			for idx, e := range c.enrichers {
+				select {
+				case <-done[idx]:
+				default:
+					errs = append(errs, &EnricherError{Enricher: e, Err: fmt.Errorf("did not finish: %w", ctx.Err())})
+				}
+			}
+			return errors.Join(errs...)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// dependencies возвращает для каждого обогатителя цепочки индексы обогатителей, заполняющих нужные ему поля.
+// Зависимости, образующие цикл, отбрасываются.
+func (c *Chain) dependencies() [][]int {
+	deps := make([][]int, len(c.enrichers))
+	for i, e := range c.enrichers {
+		required := requires(e)
+		if len(required) == 0 {
+			continue
+		}
+
+		for j, other := range c.enrichers {
+			if i != j && slices.ContainsFunc(other.Fields(), func(f string) bool { return slices.Contains(required, f) }) {
+				deps[i] = append(deps[i], j)
+			}
+		}
+	}
+
+	// обход в глубину: ребро в обогатитель, который ещё обходится, замыкает цикл
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(c.enrichers))
+	var visit func(i int)
+	visit = func(i int) {
+		state[i] = visiting
+		kept := deps[i][:0]
+		for _, d := range deps[i] {
+			if state[d] == visiting {
+				continue
+			}
+			if state[d] == unvisited {
+				visit(d)
+			}
+			kept = append(kept, d)
+		}
+		deps[i] = kept
+		state[i] = visited
+	}
+	for i := range c.enrichers {
+		if state[i] == unvisited {
+			visit(i)
+		}
+	}
+
+	return deps
+}
+
+// lockedField возвращает первое из полей fields, значение которого заблокировано.
+func lockedField(user *model.User, fields []string) (string, bool) {
+	for _, field := range fields {
+		if user.Provenance[field].Locked {
+			return field, true
+		}
+	}
+	return "", false
+}
+
+// copyFields переносит из src в dst поля с указанными именами вместе с уверенностью в них.
+func copyFields(dst, src *model.User, fields []string) {
+	for _, field := range fields {
+		if p, ok := src.Provenance[field]; ok {
+			dst.SetProvenance(field, p)
+		}
+		for _, c := range src.Conflicts {
+			if c.Field == field {
+				dst.Conflicts = append(dst.Conflicts, c)
+			}
+		}
+
+		switch field {
+		case "age":
+			dst.Age = src.Age
+			dst.AgeCount = src.AgeCount
+		case "gender":
+			dst.Gender = src.Gender
+			dst.GenderProbability = src.GenderProbability
+			dst.GenderCount = src.GenderCount
+		case "nationality":
+			dst.Nationality = src.Nationality
+			dst.NationalityProbability = src.NationalityProbability
+			dst.NationalityCount = src.NationalityCount
+			dst.Nationalities = slices.Clone(src.Nationalities)
+		}
+	}
+}
+
+// FieldValues возвращает значения полей пользователя с указанными именами вместе с уверенностью в них.
+// Ключи совпадают с именами столбцов таблицы users.
+func FieldValues(user *model.User, fields []string) map[string]any {
+	values := make(map[string]any, len(fields))
+	for _, field := range fields {
+		switch field {
+		case "age":
+			values["age"] = user.Age
+			values["age_count"] = user.AgeCount
+		case "gender":
+			values["gender"] = user.Gender
+			values["gender_probability"] = user.GenderProbability
+			values["gender_count"] = user.GenderCount
+		case "nationality":
+			values["nationality"] = user.Nationality
+			values["nationality_probability"] = user.NationalityProbability
+			values["nationality_count"] = user.NationalityCount
+		}
+	}
+	return values
+}
+
+// Changes возвращает результаты обогащения полей fields пользователя для сохранения.
+func Changes(user *model.User, fields []string) model.Enrichment {
+	changes := model.Enrichment{
+		Values:     FieldValues(user, fields),
+		Provenance: make(map[string]model.Provenance, len(fields)),
+	}
+	for _, field := range fields {
+		if p, ok := user.Provenance[field]; ok {
+			changes.Provenance[field] = p
+		}
+		if field == "nationality" {
+			changes.Nationalities = user.Nationalities
+		}
+	}
+	for _, c := range user.Conflicts {
+		if slices.Contains(fields, c.Field) {
+			changes.Conflicts = append(changes.Conflicts, c)
+		}
+	}
+	return changes
+}
+
+// WithTimeout ограничивает время работы обогатителя. Если timeout не больше нуля, e возвращается без изменений.
+func WithTimeout(e Enricher, timeout time.Duration) Enricher {
+	if timeout <= 0 {
+		return e
+	}
+	return &timeoutEnricher{Enricher: e, timeout: timeout}
+}
+
+type timeoutEnricher struct {
+	Enricher
+	timeout time.Duration
+}
+
+func (e *timeoutEnricher) Requires() []string {
+	return requires(e.Enricher)
+}
+
+func (e *timeoutEnricher) Provider() string {
+	return providerName(e.Enricher)
+}
+
+func (e *timeoutEnricher) Enrich(ctx context.Context, user *model.User) error {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	return e.Enricher.Enrich(ctx, user)
+}
@@ -0,0 +1,185 @@
+package enricher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	_ "embed"
+	"strings"
+	"time"
+
+	"github.com/aachex/service/internal/model"
+)
+
+// DictionaryProvider - имя источника в происхождении значений, определённых по словарям.
+const DictionaryProvider = "dictionary"
+
+//go:embed dictionary/ru_names.txt
+var ruNamesFile []byte
+
+// ruNames - пол по русским именам в нижнем регистре.
+var ruNames = parseNames(ruNamesFile)
+
+// parseNames читает словарь имён: по одному имени и его полу (m или f) в строке, # начинает комментарий.
+func parseNames(b []byte) map[string]string {
+	names := make(map[string]string)
+
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		switch fields[1] {
+		case "m":
+			names[fields[0]] = "male"
+		case "f":
+			names[fields[0]] = "female"
+		}
+	}
+	return names
+}
+
+// suffixRule сопоставляет окончание слова со значением поля.
+type suffixRule struct {
+	suffix      string
+	value       string
+	probability float64
+}
+
+// Окончания отчеств. Более длинные окончания проверяются раньше.
+var patronymicRules = []suffixRule{
+	{"ична", "female", 0.99}, {"ichna", "female", 0.99},
+	{"вна", "female", 0.99}, {"vna", "female", 0.99},
+	{"вич", "male", 0.99}, {"vich", "male", 0.99},
+	{"ич", "male", 0.97}, {"ich", "male", 0.97},
+}
+
+// Окончания фамилий, по которым определяется пол. Латинские -in и -ina не используются:
+// так оканчиваются и нерусские фамилии, например Martin и Medina.
+var surnameGenderRules = []suffixRule{
+	{"ская", "female", 0.95}, {"цкая", "female", 0.95}, {"skaya", "female", 0.95},
+	{"ова", "female", 0.9}, {"ева", "female", 0.9}, {"ёва", "female", 0.9}, {"ина", "female", 0.85},
+	{"ova", "female", 0.9}, {"eva", "female", 0.9},
+	{"ский", "male", 0.95}, {"цкий", "male", 0.95}, {"sky", "male", 0.95}, {"skiy", "male", 0.95},
+	{"ов", "male", 0.9}, {"ев", "male", 0.9}, {"ёв", "male", 0.9}, {"ин", "male", 0.85},
+	{"ov", "male", 0.9}, {"ev", "male", 0.9},
+}
+
+// Окончания фамилий, по которым определяется национальность. Латинские -ov и -ev не используются:
+// так пишутся и болгарские, и македонские фамилии, например Dimov.
+var surnameNationalityRules = []suffixRule{
+	{"енко", "UA", 0.8}, {"enko", "UA", 0.8}, {"чук", "UA", 0.7}, {"chuk", "UA", 0.7},
+	{"ский", "RU", 0.6}, {"ская", "RU", 0.6}, {"цкий", "RU", 0.6}, {"цкая", "RU", 0.6},
+	{"ов", "RU", 0.7}, {"ова", "RU", 0.7}, {"ев", "RU", 0.7}, {"ева", "RU", 0.7},
+	{"ёв", "RU", 0.7}, {"ёва", "RU", 0.7}, {"ин", "RU", 0.6}, {"ина", "RU", 0.6},
+}
+
+// matchSuffix возвращает первое правило, окончание которого совпадает с окончанием word.
+func matchSuffix(rules []suffixRule, word string) (suffixRule, bool) {
+	word = strings.ToLower(strings.TrimSpace(word))
+	for _, r := range rules {
+		// слово должно быть длиннее окончания, иначе "Ин" или "Ева" совпадут сами с собой
+		if len(word) > len(r.suffix) && strings.HasSuffix(word, r.suffix) {
+			return r, true
+		}
+	}
+	return suffixRule{}, false
+}
+
+// dictionaryProvenance возвращает происхождение значения, определённого правилом rule.
+func dictionaryProvenance(rule string) model.Provenance {
+	return model.Provenance{
+		Source:    model.SourceEnricher,
+		Provider:  DictionaryProvider,
+		Raw:       rule,
+		UpdatedAt: time.Now(),
+	}
+}
+
+// DictionaryGenderEnricher определяет пол пользователя без обращения к сети: по окончанию отчества,
+// по встроенному словарю русских имён или по окончанию фамилии - в этом порядке.
+type DictionaryGenderEnricher struct {
+	minProbability float64
+}
+
+// NewDictionaryGenderEnricher создаёт обогатитель. Предположения с вероятностью ниже minProbability
+// не используются, как и такие же ответы внешнего сервиса.
+func NewDictionaryGenderEnricher(minProbability float64) *DictionaryGenderEnricher {
+	return &DictionaryGenderEnricher{minProbability: minProbability}
+}
+
+func (e *DictionaryGenderEnricher) Name() string {
+	return "gender"
+}
+
+func (e *DictionaryGenderEnricher) Fields() []string {
+	return []string{"gender"}
+}
+
+func (e *DictionaryGenderEnricher) Provider() string {
+	return DictionaryProvider
+}
+
+func (e *DictionaryGenderEnricher) Enrich(ctx context.Context, user *model.User) error {
+	set := func(gender string, probability float64, rule string) error {
+		if probability < e.minProbability {
+			return ErrNoData
+		}
+		user.Gender = gender
+		user.GenderProbability = probability
+		user.GenderCount = 0
+		user.SetProvenance("gender", dictionaryProvenance(rule))
+		return nil
+	}
+
+	if r, ok := matchSuffix(patronymicRules, user.Patronymic); ok {
+		return set(r.value, r.probability, "patronymic suffix -"+r.suffix)
+	}
+	if gender, ok := ruNames[strings.ToLower(strings.TrimSpace(user.Name))]; ok {
+		return set(gender, 0.95, "name dictionary")
+	}
+	if r, ok := matchSuffix(surnameGenderRules, user.Surname); ok {
+		return set(r.value, r.probability, "surname suffix -"+r.suffix)
+	}
+	return ErrNoData
+}
+
+// DictionaryNationalityEnricher предполагает национальность пользователя по окончанию фамилии без обращения к сети.
+type DictionaryNationalityEnricher struct {
+	minProbability float64
+}
+
+// NewDictionaryNationalityEnricher создаёт обогатитель. Предположения с вероятностью ниже minProbability
+// не используются, как и такие же ответы внешнего сервиса.
+func NewDictionaryNationalityEnricher(minProbability float64) *DictionaryNationalityEnricher {
+	return &DictionaryNationalityEnricher{minProbability: minProbability}
+}
+
+func (e *DictionaryNationalityEnricher) Name() string {
+	return "nationality"
+}
+
+func (e *DictionaryNationalityEnricher) Fields() []string {
+	return []string{"nationality"}
+}
+
+func (e *DictionaryNationalityEnricher) Provider() string {
+	return DictionaryProvider
+}
+
+func (e *DictionaryNationalityEnricher) Enrich(ctx context.Context, user *model.User) error {
+	r, ok := matchSuffix(surnameNationalityRules, user.Surname)
+	if !ok || r.probability < e.minProbability {
+		return ErrNoData
+	}
+
+	user.Nationality = r.value
+	user.NationalityProbability = r.probability
+	user.NationalityCount = 0
+	user.Nationalities = []model.Nationality{{Country: r.value, Probability: r.probability}}
+	user.SetProvenance("nationality", dictionaryProvenance("surname suffix -"+r.suffix))
+	return nil
+}
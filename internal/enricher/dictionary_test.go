@@ -0,0 +1,106 @@
+package enricher
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aachex/service/internal/enricher/fake"
+	"github.com/aachex/service/internal/model"
+)
+
+func TestDictionaryGender(t *testing.T) {
+	tests := []struct {
+		user   model.User
+		gender string
+		rule   string
+	}{
+		{model.User{Name: "Саша", Surname: "Петрова", Patronymic: "Ивановна"}, "female", "patronymic suffix -вна"},
+		{model.User{Name: "Sasha", Surname: "Petrov", Patronymic: "Ilyich"}, "male", "patronymic suffix -ich"},
+		{model.User{Name: "Ольга", Surname: "Smith"}, "female", "name dictionary"},
+		{model.User{Name: "Женя", Surname: "Вишневская"}, "female", "surname suffix -ская"},
+		{model.User{Name: "Zhenya", Surname: "Ivanov"}, "male", "surname suffix -ov"},
+		{model.User{Name: "Alex", Surname: "Medina"}, "", ""},
+	}
+
+	e := NewDictionaryGenderEnricher(0)
+	for _, tt := range tests {
+		u := tt.user
+		err := e.Enrich(t.Context(), &u)
+
+		if tt.gender == "" {
+			if !errors.Is(err, ErrNoData) {
+				t.Errorf("%s %s: wanted ErrNoData, got %v", u.Name, u.Surname, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %s: %v", u.Name, u.Surname, err)
+			continue
+		}
+		if u.Gender != tt.gender || u.Provenance["gender"].Raw != tt.rule {
+			t.Errorf("%s %s: wanted %s by %q, got %s by %q",
+				u.Name, u.Surname, tt.gender, tt.rule, u.Gender, u.Provenance["gender"].Raw)
+		}
+	}
+}
+
+func TestDictionaryNationality(t *testing.T) {
+	e := NewDictionaryNationalityEnricher(0)
+
+	u := model.User{Surname: "Шевченко"}
+	if err := e.Enrich(t.Context(), &u); err != nil || u.Nationality != "UA" {
+		t.Errorf("wanted UA, got %q (%v)", u.Nationality, err)
+	}
+
+	// латинские -ov и -ev бывают и у болгарских фамилий
+	for _, surname := range []string{"Smith", "Dimov"} {
+		u = model.User{Surname: surname}
+		if err := e.Enrich(t.Context(), &u); !errors.Is(err, ErrNoData) {
+			t.Errorf("%s: wanted ErrNoData, got %v", surname, err)
+		}
+	}
+
+	// предположение по окончанию -ин ниже порога
+	u = model.User{Surname: "Пушкин"}
+	if err := NewDictionaryNationalityEnricher(0.65).Enrich(t.Context(), &u); !errors.Is(err, ErrNoData) {
+		t.Errorf("guess below the threshold was used: %q (%v)", u.Nationality, err)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	srv, cfg := newFakeProviders(t)
+	gender := Fallback(NewDictionaryGenderEnricher(0), NewGenderEnricher(cfg.Genderize))
+
+	// словарь знает отчество, к недоступному внешнему сервису не обращаемся
+	srv.Inject(fake.PathGenderize, fake.Fault{Status: http.StatusServiceUnavailable})
+	u := model.User{Name: "Sasha", Patronymic: "Petrovna"}
+	if err := gender.Enrich(t.Context(), &u); err != nil || u.Gender != "female" {
+		t.Errorf("wanted female from dictionary, got %q (%v)", u.Gender, err)
+	}
+
+	// словарь не знает имени, итог определяет ошибка внешнего сервиса
+	u = model.User{Name: "Sasha"}
+	report := NewReport(gender, gender.Enrich(t.Context(), &u))
+	if o := report["gender"]; o.Status != StatusProviderError || o.Provider != "dictionary,genderize" {
+		t.Errorf("unexpected outcome %+v", o)
+	}
+
+	// внешний сервис снова доступен и отвечает вместо словаря
+	srv.Reset()
+	u = model.User{Name: "Sasha"}
+	if err := gender.Enrich(t.Context(), &u); err != nil || u.Provenance["gender"].Provider != "genderize" {
+		t.Errorf("genderize wasn't used as a fallback: %+v (%v)", u.Provenance["gender"], err)
+	}
+}
+
+func TestFallbackAfterTimeout(t *testing.T) {
+	// первый обогатитель не отвечает, второй успевает в оставшееся время
+	e := WithTimeout(Fallback(&stubEnricher{name: "age", delay: time.Hour}, &stubEnricher{name: "age", age: 42}), 100*time.Millisecond)
+
+	u := model.User{Name: "Ivan"}
+	if err := e.Enrich(t.Context(), &u); err != nil || u.Age != 42 {
+		t.Errorf("secondary wasn't used after primary timed out: age %d (%v)", u.Age, err)
+	}
+}
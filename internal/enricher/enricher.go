@@ -1,92 +1,190 @@
 package enricher
 
 import (
-	"encoding/json"
-	"io"
-	"net/http"
+	"context"
 
 	"github.com/aachex/service/internal/model"
 )
 
-type enricher = func(user *model.User) error
+// Enricher дополняет пользователя данными из внешнего источника.
+type Enricher interface {
+	// Name возвращает имя, по которому обогатитель можно выбрать в запросе.
+	Name() string
 
-func EnrichUser(user *model.User) error {
-	enrichers := []enricher{
-		EnrichAge,
-		EnrichGender,
-		EnrichNationality,
+	// Fields возвращает имена полей пользователя, которые заполняет обогатитель.
+	Fields() []string
+
+	// Enrich заполняет поля пользователя. Если источник ничего не знает о пользователе,
+	// поля не меняются и возвращается ErrNoData.
+	Enrich(ctx context.Context, user *model.User) error
+}
+
+// Dependent - обогатитель, которому нужны поля, заполняемые другими обогатителями.
+// В цепочке такой обогатитель запускается после них.
+type Dependent interface {
+	// Requires возвращает имена полей, которые нужно заполнить до запуска обогатителя.
+	Requires() []string
+}
+
+// requires возвращает поля, нужные обогатителю e, если он реализует Dependent.
+func requires(e Enricher) []string {
+	if d, ok := e.(Dependent); ok {
+		return d.Requires()
 	}
+	return nil
+}
 
-	for _, enrich := range enrichers {
-		err := enrich(user)
-		if err != nil {
-			return err
-		}
+// Builtin возвращает встроенные обогатители в порядке по умолчанию.
+func Builtin(cfg ProvidersConfig, opts ...ProviderOption) []Enricher {
+	return []Enricher{
+		NewAgeEnricher(cfg.Agify, opts...),
+		NewGenderEnricher(cfg.Genderize, opts...),
+		NewNationalityEnricher(cfg.Nationalize, opts...),
 	}
+}
+
+// AgeEnricher определяет возраст пользователя по имени с помощью agify.io.
+type AgeEnricher struct {
+	provider
+}
 
+func NewAgeEnricher(cfg ProviderConfig, opts ...ProviderOption) *AgeEnricher {
+	return &AgeEnricher{newProvider("agify", AgifyURL, cfg, opts)}
+}
+
+func (e *AgeEnricher) Name() string {
+	return "age"
+}
+
+func (e *AgeEnricher) Fields() []string {
+	return []string{"age"}
+}
+
+// Requires возвращает национальность, если включена локализация: возраст уточняется по стране.
+func (e *AgeEnricher) Requires() []string {
+	if e.cfg.Localize {
+		return []string{"nationality"}
+	}
 	return nil
 }
 
-func EnrichAge(user *model.User) error {
+func (e *AgeEnricher) Enrich(ctx context.Context, user *model.User) error {
 	type resBody struct {
-		Age int `json:"age"`
+		Age   int `json:"age"`
+		Count int `json:"count"`
 	}
 
-	body, err := httpGet[resBody]("https://api.agify.io/?name=" + user.Name)
+	body, raw, found, err := lookupLocalized(ctx, e.provider, user.Name, user.Nationality, func(b resBody) bool { return b.Age > 0 })
 	if err != nil {
 		return err
 	}
+	if !found {
+		return ErrNoData
+	}
+	if err = e.confident(1, body.Count); err != nil {
+		return err
+	}
 
 	user.Age = body.Age
+	user.AgeCount = body.Count
+	user.SetProvenance("age", e.provenance(raw))
+	return nil
+}
+
+// GenderEnricher определяет пол пользователя по имени с помощью genderize.io.
+type GenderEnricher struct {
+	provider
+}
+
+func NewGenderEnricher(cfg ProviderConfig, opts ...ProviderOption) *GenderEnricher {
+	return &GenderEnricher{newProvider("genderize", GenderizeURL, cfg, opts)}
+}
+
+func (e *GenderEnricher) Name() string {
+	return "gender"
+}
+
+func (e *GenderEnricher) Fields() []string {
+	return []string{"gender"}
+}
+
+// Requires возвращает национальность, если включена локализация: пол уточняется по стране.
+func (e *GenderEnricher) Requires() []string {
+	if e.cfg.Localize {
+		return []string{"nationality"}
+	}
 	return nil
 }
 
-func EnrichGender(user *model.User) error {
+func (e *GenderEnricher) Enrich(ctx context.Context, user *model.User) error {
 	type resBody struct {
-		Gender string `json:"gender"`
+		Gender      string  `json:"gender"`
+		Probability float64 `json:"probability"`
+		Count       int     `json:"count"`
 	}
 
-	body, err := httpGet[resBody]("https://api.genderize.io/?name=" + user.Name)
+	body, raw, found, err := lookupLocalized(ctx, e.provider, user.Name, user.Nationality, func(b resBody) bool { return b.Gender != "" })
 	if err != nil {
 		return err
 	}
+	if !found {
+		return ErrNoData
+	}
+	if err = e.confident(body.Probability, body.Count); err != nil {
+		return err
+	}
 
 	user.Gender = body.Gender
+	user.GenderProbability = body.Probability
+	user.GenderCount = body.Count
+	user.SetProvenance("gender", e.provenance(raw))
 	return nil
 }
 
-func EnrichNationality(user *model.User) error {
+// NationalityEnricher определяет национальность пользователя по фамилии с помощью nationalize.io.
+type NationalityEnricher struct {
+	provider
+}
+
+func NewNationalityEnricher(cfg ProviderConfig, opts ...ProviderOption) *NationalityEnricher {
+	return &NationalityEnricher{newProvider("nationalize", NationalizeURL, cfg, opts)}
+}
+
+func (e *NationalityEnricher) Name() string {
+	return "nationality"
+}
+
+func (e *NationalityEnricher) Fields() []string {
+	return []string{"nationality"}
+}
+
+func (e *NationalityEnricher) Enrich(ctx context.Context, user *model.User) error {
 	type resBody struct {
+		Count   int `json:"count"`
 		Country []struct {
-			Id string `json:"country_id"`
+			Id          string  `json:"country_id"`
+			Probability float64 `json:"probability"`
 		}
 	}
 
-	body, err := httpGet[resBody]("https://api.nationalize.io/?name=" + user.Surname)
+	body, raw, found, err := lookup(ctx, e.provider, user.Surname, "", func(b resBody) bool { return len(b.Country) > 0 })
 	if err != nil {
 		return err
 	}
-	if len(body.Country) > 0 {
-		user.Nationality = body.Country[0].Id
+	if !found {
+		return ErrNoData
 	}
-	return nil
-}
-
-func httpGet[T any](url string) (body T, err error) {
-	res, err := http.Get(url)
-	if err != nil {
-		return body, err
-	}
-	defer res.Body.Close()
-
-	b, err := io.ReadAll(res.Body)
-	if err != nil {
-		return body, err
+	if err = e.confident(body.Country[0].Probability, body.Count); err != nil {
+		return err
 	}
 
-	err = json.Unmarshal(b, &body)
-	if err != nil {
-		return body, err
+	user.Nationality = body.Country[0].Id
+	user.NationalityProbability = body.Country[0].Probability
+	user.NationalityCount = body.Count
+	user.Nationalities = make([]model.Nationality, len(body.Country))
+	for i, c := range body.Country {
+		user.Nationalities[i] = model.Nationality{Country: c.Id, Probability: c.Probability}
 	}
-	return body, nil
+	user.SetProvenance("nationality", e.provenance(raw))
+	return nil
 }
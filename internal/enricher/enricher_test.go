@@ -1,8 +1,16 @@
 package enricher
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/aachex/service/internal/enricher/fake"
 	"github.com/aachex/service/internal/model"
 )
 
@@ -13,38 +21,416 @@ var user = model.User{
 	Patronymic: "Dmitrievich",
 }
 
+// newFakeProviders запускает фейковые сервисы со встроенной таблицей ответов и возвращает настройки для них.
+func newFakeProviders(t *testing.T) (*fake.Server, ProvidersConfig) {
+	srv := fake.NewServer(fake.DefaultTable())
+	t.Cleanup(srv.Close)
+
+	return srv, ProvidersConfig{
+		Agify:       ProviderConfig{BaseURL: srv.AgifyURL()},
+		Genderize:   ProviderConfig{BaseURL: srv.GenderizeURL()},
+		Nationalize: ProviderConfig{BaseURL: srv.NationalizeURL()},
+	}
+}
+
 func TestEnrichUser(t *testing.T) {
-	err := EnrichUser(&user)
+	_, cfg := newFakeProviders(t)
+	registry, err := NewRegistry(Builtin(cfg)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := registry.Select()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := user
+	err = chain.Enrich(t.Context(), &u)
 	if err != nil {
 		t.Error(err)
 	}
+
+	if u.Age != 41 || u.Gender != "male" || u.Nationality != "BG" {
+		t.Errorf("unexpected enrichment result %+v", u)
+	}
 }
 
 func TestEnrichAge(t *testing.T) {
-	err := EnrichAge(&user)
+	_, cfg := newFakeProviders(t)
+
+	u := user
+	err := NewAgeEnricher(cfg.Agify).Enrich(t.Context(), &u)
 	if err != nil {
 		t.Error(err)
 	}
+
+	if u.Age != 41 || u.AgeCount != 52113 {
+		t.Errorf("unexpected age %d (count %d)", u.Age, u.AgeCount)
+	}
 }
 
 func TestEnrichGender(t *testing.T) {
-	err := EnrichGender(&user)
+	_, cfg := newFakeProviders(t)
+
+	u := user
+	err := NewGenderEnricher(cfg.Genderize).Enrich(t.Context(), &u)
 	if err != nil {
 		t.Error(err)
 	}
 
-	if user.Gender == "" {
+	if u.Gender == "" {
 		t.Error("gender is empty")
 	}
 }
 
 func TestEnrichNationality(t *testing.T) {
-	err := EnrichNationality(&user)
+	_, cfg := newFakeProviders(t)
+
+	u := user
+	err := NewNationalityEnricher(cfg.Nationalize).Enrich(t.Context(), &u)
 	if err != nil {
 		t.Error(err)
 	}
 
-	if user.Nationality == "" {
+	if u.Nationality == "" {
 		t.Error("nationality is empty")
 	}
+
+	// сохраняется весь список стран по убыванию вероятности, а не только первая
+	want := []model.Nationality{{Country: "BG", Probability: 0.83}, {Country: "MK", Probability: 0.06}}
+	if !slices.Equal(u.Nationalities, want) {
+		t.Errorf("wanted nationalities %v, got %v", want, u.Nationalities)
+	}
+}
+
+func TestEnrichUnknownName(t *testing.T) {
+	_, cfg := newFakeProviders(t)
+
+	u := model.User{Name: "Zyxw", Surname: "Qwrtz"}
+	for _, e := range Builtin(cfg) {
+		if err := e.Enrich(t.Context(), &u); !errors.Is(err, ErrNoData) {
+			t.Errorf("%s: wanted ErrNoData, got %v", e.Name(), err)
+		}
+	}
+}
+
+func TestProviderFaults(t *testing.T) {
+	srv, cfg := newFakeProviders(t)
+	cfg.Agify.Timeout = 50 * time.Millisecond
+
+	tests := []struct {
+		name  string
+		fault fake.Fault
+		check func(err error) bool
+	}{
+		{"rate limited", fake.Fault{Status: http.StatusTooManyRequests}, func(err error) bool {
+			return errors.Is(err, ErrRateLimited)
+		}},
+		{"server error", fake.Fault{Status: http.StatusServiceUnavailable}, func(err error) bool {
+			var se *StatusError
+			return errors.As(err, &se) && se.StatusCode == http.StatusServiceUnavailable
+		}},
+		{"malformed json", fake.Fault{Malformed: true}, func(err error) bool {
+			return err != nil && !errors.Is(err, ErrNoData)
+		}},
+		{"latency", fake.Fault{Latency: time.Second}, func(err error) bool {
+			return errors.Is(err, context.DeadlineExceeded)
+		}},
+	}
+
+	for _, tt := range tests {
+		srv.Inject(fake.PathAgify, tt.fault)
+
+		u := user
+		err := NewAgeEnricher(cfg.Agify).Enrich(t.Context(), &u)
+		if !tt.check(err) {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if u.Age != 0 {
+			t.Errorf("%s: age was set to %d", tt.name, u.Age)
+		}
+	}
+
+	// после сброса сбоев сервис снова отвечает
+	srv.Reset()
+	u := user
+	if err := NewAgeEnricher(cfg.Agify).Enrich(t.Context(), &u); err != nil || u.Age != 41 {
+		t.Errorf("provider didn't recover: age %d, error %v", u.Age, err)
+	}
+}
+
+func TestRegistrySelect(t *testing.T) {
+	registry, err := NewRegistry(Builtin(ProvidersConfig{})...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := registry.Select("gender", "age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(chain.Fields(), []string{"gender", "age"}) {
+		t.Errorf("unexpected fields %v", chain.Fields())
+	}
+
+	_, err = registry.Select("age", "zodiac")
+	if !errors.Is(err, ErrUnknownEnricher) {
+		t.Errorf("wanted ErrUnknownEnricher, got %v", err)
+	}
+
+	_, err = NewRegistry(NewAgeEnricher(ProviderConfig{}), NewAgeEnricher(ProviderConfig{}))
+	if !errors.Is(err, ErrDuplicateEnricher) {
+		t.Errorf("wanted ErrDuplicateEnricher, got %v", err)
+	}
+
+	// другой обогатитель того же поля без арбитра не регистрируется
+	_, err = NewRegistry(NewAgeEnricher(ProviderConfig{}), &stubEnricher{name: "plugin"})
+	if !errors.Is(err, ErrFieldOverlap) {
+		t.Errorf("wanted ErrFieldOverlap, got %v", err)
+	}
+}
+
+// stubEnricher заполняет возраст после задержки delay или возвращает err.
+type stubEnricher struct {
+	name  string
+	delay time.Duration
+	age   int
+	err   error
+}
+
+func (e *stubEnricher) Name() string     { return e.name }
+func (e *stubEnricher) Fields() []string { return []string{"age"} }
+
+func (e *stubEnricher) Enrich(ctx context.Context, user *model.User) error {
+	select {
+	case <-time.After(e.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if e.err != nil {
+		return e.err
+	}
+	user.Age = e.age
+	return nil
+}
+
+func TestChainBudget(t *testing.T) {
+	fast := &stubEnricher{name: "fast", age: 30}
+	slow := &stubEnricher{name: "slow", delay: time.Hour, age: 99}
+
+	u := model.User{Name: "Ivan"}
+	err := NewChain(50*time.Millisecond, slow, fast).Enrich(t.Context(), &u)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wanted context.DeadlineExceeded, got %v", err)
+	}
+	if u.Age != 30 {
+		t.Errorf("wanted age of the fast enricher, got %d", u.Age)
+	}
+}
+
+func TestChainKeepsOtherResultsOnError(t *testing.T) {
+	failing := &stubEnricher{name: "failing", err: errors.New("provider is down")}
+	ok := &stubEnricher{name: "ok", age: 42}
+
+	u := model.User{Name: "Ivan"}
+	err := NewChain(0, failing, ok).Enrich(t.Context(), &u)
+	if err == nil {
+		t.Error("wanted error of the failing enricher")
+	}
+	if u.Age != 42 {
+		t.Errorf("wanted age 42, got %d", u.Age)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	slow := WithTimeout(&stubEnricher{name: "slow", delay: time.Hour}, 20*time.Millisecond)
+
+	start := time.Now()
+	err := slow.Enrich(t.Context(), &model.User{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wanted context.DeadlineExceeded, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("timeout was not applied")
+	}
+	if slow.Name() != "slow" {
+		t.Errorf("wrapped enricher name changed to %q", slow.Name())
+	}
+}
+
+func TestProviderConfig(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Write([]byte(`{"name":"Иван","age":41}`))
+	}))
+	defer srv.Close()
+
+	e := NewAgeEnricher(ProviderConfig{
+		BaseURL:   srv.URL,
+		APIKey:    "secret",
+		UserAgent: "users-service",
+	}, WithHTTPClient(srv.Client()))
+
+	u := model.User{Name: "Иван"}
+	if err := e.Enrich(t.Context(), &u); err != nil {
+		t.Fatal(err)
+	}
+
+	if u.Age != 41 {
+		t.Errorf("wanted age 41, got %d", u.Age)
+	}
+	if q := got.URL.Query(); q.Get("name") != "Иван" || q.Get("apikey") != "secret" {
+		t.Errorf("unexpected query %q", got.URL.RawQuery)
+	}
+	if got.UserAgent() != "users-service" {
+		t.Errorf("unexpected user agent %q", got.UserAgent())
+	}
+}
+
+type countryEnricher struct{}
+
+func (countryEnricher) Name() string       { return "nationality" }
+func (countryEnricher) Fields() []string   { return []string{"nationality"} }
+func (countryEnricher) Requires() []string { return nil }
+
+func (countryEnricher) Enrich(ctx context.Context, user *model.User) error {
+	time.Sleep(20 * time.Millisecond)
+	user.Nationality = "RU"
+	return nil
+}
+
+type localizedEnricher struct{}
+
+func (localizedEnricher) Name() string       { return "age" }
+func (localizedEnricher) Fields() []string   { return []string{"age"} }
+func (localizedEnricher) Requires() []string { return []string{"nationality"} }
+
+func (localizedEnricher) Enrich(ctx context.Context, user *model.User) error {
+	if user.Nationality == "RU" {
+		user.Age = 40
+	}
+	return nil
+}
+
+func TestChainDependencies(t *testing.T) {
+	u := model.User{Name: "Иван"}
+	if err := NewChain(time.Second, localizedEnricher{}, countryEnricher{}).Enrich(t.Context(), &u); err != nil {
+		t.Fatal(err)
+	}
+
+	if u.Nationality != "RU" || u.Age != 40 {
+		t.Errorf("dependent enricher ran before its dependency: %+v", u)
+	}
+}
+
+func TestLocalizedFallback(t *testing.T) {
+	var countries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		country := r.URL.Query().Get("country_id")
+		countries = append(countries, country)
+		if country != "" {
+			w.Write([]byte(`{"name":"Иван","age":null,"country_id":"` + country + `"}`))
+			return
+		}
+		w.Write([]byte(`{"name":"Иван","age":41}`))
+	}))
+	defer srv.Close()
+
+	e := NewAgeEnricher(ProviderConfig{BaseURL: srv.URL, Localize: true}, WithHTTPClient(srv.Client()))
+
+	u := model.User{Name: "Иван", Nationality: "RU"}
+	if err := e.Enrich(t.Context(), &u); err != nil {
+		t.Fatal(err)
+	}
+
+	if u.Age != 41 {
+		t.Errorf("wanted age 41, got %d", u.Age)
+	}
+	if !slices.Equal(countries, []string{"RU", ""}) {
+		t.Errorf("unexpected lookups %q", countries)
+	}
+}
+
+func TestConfidenceThreshold(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name":"sasha","gender":"male","probability":0.55,"count":800}`))
+	}))
+	defer srv.Close()
+
+	u := model.User{Name: "Sasha"}
+	err := NewGenderEnricher(ProviderConfig{BaseURL: srv.URL}).Enrich(t.Context(), &u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Gender != "male" || u.GenderProbability != 0.55 || u.GenderCount != 800 {
+		t.Errorf("confidence wasn't stored: %+v", u)
+	}
+
+	u = model.User{Name: "Sasha"}
+	err = NewGenderEnricher(ProviderConfig{BaseURL: srv.URL, MinProbability: 0.8}).Enrich(t.Context(), &u)
+	if !errors.Is(err, ErrNoData) {
+		t.Fatalf("wanted ErrNoData, got %v", err)
+	}
+	if u.Gender != "" {
+		t.Errorf("gender %q below threshold wasn't dropped", u.Gender)
+	}
+}
+
+func TestReport(t *testing.T) {
+	down := &stubEnricher{name: "down", err: errors.New("provider is down")}
+	unknown := &stubEnricher{name: "unknown", err: ErrNoData}
+	gender := NewGenderEnricher(ProviderConfig{BaseURL: "http://127.0.0.1:0"},
+		WithBreaker(BreakerConfig{Threshold: 1, Cooldown: time.Hour}))
+	gender.Breaker().Failure(t.Context(), errors.New("provider is down"), nil)
+
+	tests := []struct {
+		enricher Enricher
+		field    string
+		status   string
+		provider string
+	}{
+		{&stubEnricher{name: "ok"}, "age", StatusOK, "ok"},
+		{down, "age", StatusProviderError, "down"},
+		{unknown, "age", StatusNoData, "unknown"},
+		{WithTimeout(gender, time.Second), "gender", StatusSkipped, "genderize"},
+		{&stubEnricher{name: "slow", delay: time.Hour}, "age", StatusProviderError, "slow"},
+	}
+
+	for _, tt := range tests {
+		chain := NewChain(20*time.Millisecond, tt.enricher)
+		report := NewReport(chain, chain.Enrich(t.Context(), &model.User{Name: "Ivan"}))
+
+		o := report[tt.field]
+		if o.Status != tt.status || o.Provider != tt.provider {
+			t.Errorf("%s: wanted %s from %s, got %+v", tt.enricher.Name(), tt.status, tt.provider, o)
+		}
+		if o.Failed() != (tt.status == StatusProviderError || tt.status == StatusSkipped) {
+			t.Errorf("%s: unexpected Failed() for %s", tt.enricher.Name(), o.Status)
+		}
+	}
+}
+
+func TestChainSkipsLockedFields(t *testing.T) {
+	_, cfg := newFakeProviders(t)
+	chain := NewChain(time.Second, Builtin(cfg)...)
+
+	u := user
+	u.Age = 62
+	u.SetProvenance("age", model.Provenance{Source: model.SourceOperator, Locked: true})
+
+	report := NewReport(chain, chain.Enrich(t.Context(), &u))
+
+	if u.Age != 62 {
+		t.Errorf("locked age was overwritten with %d", u.Age)
+	}
+	if o := report["age"]; o.Status != StatusSkipped || o.Failed() {
+		t.Errorf("unexpected age outcome %+v", o)
+	}
+
+	p := u.Provenance["gender"]
+	if p.Source != model.SourceEnricher || p.Provider != "genderize" || !strings.Contains(p.Raw, `"male"`) {
+		t.Errorf("unexpected gender provenance %+v", p)
+	}
 }
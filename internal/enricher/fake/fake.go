@@ -0,0 +1,216 @@
+// Package fake реализует фейковые agify, genderize и nationalize с детерминированными ответами
+// из таблицы имён. Он нужен для тестов и для запуска сервиса без доступа к сети.
+package fake
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// Пути, по которым Handler обслуживает фейковые сервисы.
+const (
+	PathAgify       = "/agify"
+	PathGenderize   = "/genderize"
+	PathNationalize = "/nationalize"
+	PathFaults      = "/faults"
+)
+
+// Fault - сбой, который Handler имитирует в ответах сервиса.
+type Fault struct {
+	Latency   time.Duration // задержка перед ответом
+	Status    int           // код ответа вместо 200, например 429 или 503
+	Malformed bool          // ответ с некорректным JSON
+}
+
+// Handler отвечает на запросы к фейковым сервисам и позволяет имитировать их сбои.
+type Handler struct {
+	table Table
+	mux   *http.ServeMux
+
+	mu     sync.Mutex
+	faults map[string]Fault
+}
+
+func NewHandler(table Table) *Handler {
+	h := &Handler{
+		table:  table,
+		mux:    http.NewServeMux(),
+		faults: make(map[string]Fault),
+	}
+
+	h.mux.HandleFunc("GET "+PathAgify, h.serve(PathAgify, h.agify))
+	h.mux.HandleFunc("GET "+PathGenderize, h.serve(PathGenderize, h.genderize))
+	h.mux.HandleFunc("GET "+PathNationalize, h.serve(PathNationalize, h.nationalize))
+	h.mux.HandleFunc("PUT "+PathFaults+"/{service}", h.putFault)
+	h.mux.HandleFunc("DELETE "+PathFaults, h.deleteFaults)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// Inject включает сбой f для сервиса path, например PathAgify. Нулевой f выключает сбой.
+func (h *Handler) Inject(path string, f Fault) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if f == (Fault{}) {
+		delete(h.faults, path)
+		return
+	}
+	h.faults[path] = f
+}
+
+// Reset выключает все сбои.
+func (h *Handler) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	clear(h.faults)
+}
+
+func (h *Handler) fault(path string) Fault {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.faults[path]
+}
+
+// serve оборачивает ответ сервиса path имитацией включённого для него сбоя.
+func (h *Handler) serve(path string, answer func(r *http.Request) any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f := h.fault(path)
+
+		if f.Latency > 0 {
+			select {
+			case <-time.After(f.Latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case f.Status == http.StatusTooManyRequests:
+			w.Header().Set("X-Rate-Limit-Limit", "1000")
+			w.Header().Set("X-Rate-Limit-Remaining", "0")
+			w.Header().Set("X-Rate-Limit-Reset", "3600")
+			w.WriteHeader(f.Status)
+			w.Write([]byte(`{"error":"Request limit reached"}`))
+		case f.Status != 0 && f.Status != http.StatusOK:
+			w.WriteHeader(f.Status)
+			w.Write([]byte(`{"error":"` + http.StatusText(f.Status) + `"}`))
+		case f.Malformed:
+			w.Write([]byte(`{"name":`))
+		default:
+			json.NewEncoder(w).Encode(answer(r))
+		}
+	}
+}
+
+func (h *Handler) agify(r *http.Request) any {
+	q := r.URL.Query()
+	res := map[string]any{"name": q.Get("name"), "age": nil, "count": 0}
+	if country := q.Get("country_id"); country != "" {
+		res["country_id"] = country
+	}
+
+	if n, ok := h.table.name(q.Get("name"), q.Get("country_id")); ok && n.Age > 0 {
+		res["age"] = n.Age
+		res["count"] = n.Count
+	}
+	return res
+}
+
+func (h *Handler) genderize(r *http.Request) any {
+	q := r.URL.Query()
+	res := map[string]any{"name": q.Get("name"), "gender": nil, "probability": 0, "count": 0}
+	if country := q.Get("country_id"); country != "" {
+		res["country_id"] = country
+	}
+
+	if n, ok := h.table.name(q.Get("name"), q.Get("country_id")); ok && n.Gender != "" {
+		res["gender"] = n.Gender
+		res["probability"] = n.Probability
+		res["count"] = n.Count
+	}
+	return res
+}
+
+func (h *Handler) nationalize(r *http.Request) any {
+	name := r.URL.Query().Get("name")
+	res := map[string]any{"name": name, "count": 0, "country": []Country{}}
+
+	if s, ok := h.table.surname(name); ok {
+		res["count"] = s.Count
+		res["country"] = s.Countries
+	}
+	return res
+}
+
+// putFault включает сбой для сервиса из пути запроса. Тело запроса:
+// {"latency": "200ms", "status": 503, "malformed": false}.
+func (h *Handler) putFault(w http.ResponseWriter, r *http.Request) {
+	path := "/" + r.PathValue("service")
+	if path != PathAgify && path != PathGenderize && path != PathNationalize {
+		http.Error(w, "unknown service", http.StatusNotFound)
+		return
+	}
+
+	var body struct {
+		Latency   string `json:"latency"`
+		Status    int    `json:"status"`
+		Malformed bool   `json:"malformed"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f := Fault{Status: body.Status, Malformed: body.Malformed}
+	if body.Latency != "" {
+		latency, err := time.ParseDuration(body.Latency)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.Latency = latency
+	}
+
+	h.Inject(path, f)
+}
+
+func (h *Handler) deleteFaults(w http.ResponseWriter, r *http.Request) {
+	h.Reset()
+}
+
+// Server - фейковые сервисы, запущенные на локальном порту для тестов.
+type Server struct {
+	*httptest.Server
+	*Handler
+}
+
+// NewServer запускает фейковые сервисы с таблицей ответов table. Сервер нужно остановить методом Close.
+func NewServer(table Table) *Server {
+	h := NewHandler(table)
+	return &Server{
+		Server:  httptest.NewServer(h),
+		Handler: h,
+	}
+}
+
+func (s *Server) AgifyURL() string {
+	return s.URL + PathAgify
+}
+
+func (s *Server) GenderizeURL() string {
+	return s.URL + PathGenderize
+}
+
+func (s *Server) NationalizeURL() string {
+	return s.URL + PathNationalize
+}
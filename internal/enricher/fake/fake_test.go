@@ -0,0 +1,81 @@
+package fake
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func get(t *testing.T, url string) (int, map[string]any) {
+	t.Helper()
+
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var body map[string]any
+	json.NewDecoder(res.Body).Decode(&body)
+	return res.StatusCode, body
+}
+
+func TestAnswers(t *testing.T) {
+	srv := NewServer(DefaultTable())
+	defer srv.Close()
+
+	tests := []struct {
+		url   string
+		field string
+		want  any
+	}{
+		{srv.AgifyURL() + "?name=Ivan", "age", 45.0},
+		{srv.AgifyURL() + "?name=Ivan&country_id=RU", "age", 42.0},
+		{srv.AgifyURL() + "?name=Ivan&country_id=BG", "age", nil},
+		{srv.GenderizeURL() + "?name=OLGA", "gender", "female"},
+		{srv.GenderizeURL() + "?name=Zyxw", "gender", nil},
+	}
+
+	for _, tt := range tests {
+		status, body := get(t, tt.url)
+		if status != http.StatusOK || body[tt.field] != tt.want {
+			t.Errorf("%s: wanted %s %v, got %d %v", tt.url, tt.field, tt.want, status, body)
+		}
+	}
+
+	_, body := get(t, srv.NationalizeURL()+"?name=Ivanov")
+	countries, _ := body["country"].([]any)
+	if len(countries) == 0 || countries[0].(map[string]any)["country_id"] != "RU" {
+		t.Errorf("unexpected nationalize answer %v", body)
+	}
+}
+
+func TestFaultsEndpoint(t *testing.T) {
+	srv := NewServer(DefaultTable())
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+PathFaults+PathGenderize, strings.NewReader(`{"status": 429}`))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if status, _ := get(t, srv.GenderizeURL()+"?name=Ivan"); status != http.StatusTooManyRequests {
+		t.Errorf("wanted 429 from genderize, got %d", status)
+	}
+	if status, _ := get(t, srv.AgifyURL()+"?name=Ivan"); status != http.StatusOK {
+		t.Errorf("fault leaked to agify: %d", status)
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, srv.URL+PathFaults, nil)
+	if res, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if status, _ := get(t, srv.GenderizeURL()+"?name=Ivan"); status != http.StatusOK {
+		t.Errorf("fault wasn't reset: %d", status)
+	}
+}
@@ -0,0 +1,81 @@
+package fake
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+//go:embed table.json
+var defaultTable []byte
+
+// Name - ответы agify и genderize для имени.
+type Name struct {
+	Age         int     `json:"age"`
+	Gender      string  `json:"gender"`
+	Probability float64 `json:"probability"`
+	Count       int     `json:"count"`
+}
+
+// Country - страна в ответе nationalize.
+type Country struct {
+	Id          string  `json:"country_id"`
+	Probability float64 `json:"probability"`
+}
+
+// Surname - ответ nationalize для фамилии.
+type Surname struct {
+	Count     int       `json:"count"`
+	Countries []Country `json:"countries"`
+}
+
+// Table - таблица ответов фейковых сервисов. Ключи - имена и фамилии в нижнем регистре.
+// Ответ для конкретной страны задаётся ключом вида "ivan:RU".
+type Table struct {
+	Names    map[string]Name    `json:"names"`
+	Surnames map[string]Surname `json:"surnames"`
+}
+
+// DefaultTable возвращает встроенную таблицу ответов.
+func DefaultTable() Table {
+	var t Table
+	if err := json.Unmarshal(defaultTable, &t); err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// LoadTable читает таблицу ответов из JSON-файла того же формата, что и встроенная таблица.
+func LoadTable(path string) (Table, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Table{}, err
+	}
+
+	var t Table
+	if err = json.Unmarshal(b, &t); err != nil {
+		return Table{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// name возвращает ответ для имени name в стране country. Если country пуста, возвращается общий ответ.
+func (t Table) name(name, country string) (Name, bool) {
+	n, ok := t.Names[key(name, country)]
+	return n, ok
+}
+
+func (t Table) surname(surname string) (Surname, bool) {
+	s, ok := t.Surnames[key(surname, "")]
+	return s, ok
+}
+
+func key(name, country string) string {
+	k := strings.ToLower(strings.TrimSpace(name))
+	if country != "" {
+		k += ":" + strings.ToUpper(country)
+	}
+	return k
+}
@@ -0,0 +1,69 @@
+package enricher
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aachex/service/internal/model"
+)
+
+// Fallback возвращает обогатитель, который применяет primary, а если тот не заполнил поля
+// из-за отсутствия данных или ошибки - secondary. Имя и поля берутся у primary,
+// поэтому обогатители должны заполнять одни и те же поля.
+//
+// Если у контекста есть срок, первому обогатителю достаётся только его часть, чтобы второй успел
+// ответить и тогда, когда первый не ответил вовсе.
+func Fallback(primary, secondary Enricher) Enricher {
+	return &fallbackEnricher{primary: primary, secondary: secondary}
+}
+
+// fallbackReserve задаёт долю оставшегося времени, которая оставляется второму обогатителю: 1/fallbackReserve.
+const fallbackReserve = 4
+
+type fallbackEnricher struct {
+	primary   Enricher
+	secondary Enricher
+}
+
+func (e *fallbackEnricher) Name() string {
+	return e.primary.Name()
+}
+
+func (e *fallbackEnricher) Fields() []string {
+	return e.primary.Fields()
+}
+
+// Requires возвращает поля, нужные любому из обогатителей.
+func (e *fallbackEnricher) Requires() []string {
+	fields := append(requires(e.primary), requires(e.secondary)...)
+	slices.Sort(fields)
+	return slices.Compact(fields)
+}
+
+// Provider возвращает источники обоих обогатителей в порядке обращения к ним.
+func (e *fallbackEnricher) Provider() string {
+	return providerName(e.primary) + "," + providerName(e.secondary)
+}
+
+func (e *fallbackEnricher) Enrich(ctx context.Context, user *model.User) error {
+	primaryCtx := ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		reserve := time.Until(deadline) / fallbackReserve
+		var cancel context.CancelFunc
+		primaryCtx, cancel = context.WithDeadline(ctx, deadline.Add(-reserve))
+		defer cancel()
+	}
+
+	primaryErr := e.primary.Enrich(primaryCtx, user)
+	if primaryErr == nil || ctx.Err() != nil {
+		return primaryErr
+	}
+
+	// итог определяется вторым обогатителем, ошибка первого остаётся только в тексте
+	if err := e.secondary.Enrich(ctx, user); err != nil {
+		return fmt.Errorf("%s: %v; %s: %w", providerName(e.primary), primaryErr, providerName(e.secondary), err)
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+package enricher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aachex/service/internal/model"
+)
+
+// processFields - поля, которые может заполнять внешняя программа.
+var processFields = []string{"age", "gender", "nationality"}
+
+// ProcessConfig - настройки обогатителя, который обращается к внешней программе.
+type ProcessConfig struct {
+	Name    string   // имя обогатителя; оно же указывается как провайдер в отчёте и происхождении значений
+	Command string   // путь к программе
+	Args    []string // аргументы программы
+
+	// Fields - поля, которые заполняет программа: age, gender или nationality.
+	// Requires - поля, которые нужно заполнить до запуска программы.
+	Fields   []string
+	Requires []string
+
+	// Timeout ограничивает время ответа программы на один запрос; нулевое значение - без ограничения.
+	Timeout time.Duration
+
+	// MaxConcurrency - сколько запросов к программе выполняется одновременно. По умолчанию 1.
+	MaxConcurrency int
+
+	// KeepAlive оставляет программу запущенной между запросами вместо запуска на каждый запрос.
+	KeepAlive bool
+}
+
+// ProcessEnricher обогащает пользователя с помощью внешней программы, например скрипта на Python.
+//
+// Программа получает пользователя в формате JSON на stdin и отвечает на stdout объектом JSON с полями
+// пользователя, которые удалось определить, например {"gender": "female", "gender_probability": 0.9}.
+// Из ответа берутся только поля из ProcessConfig.Fields вместе с уверенностью в них. Если ни одного
+// такого поля в ответе нет, считается, что программа ничего не знает о пользователе.
+//
+// Без KeepAlive программа запускается на каждый запрос, получает пользователя и закрытый после него stdin
+// и должна завершиться с кодом 0. С KeepAlive программа работает постоянно: запросы и ответы - по одному
+// объекту JSON в строке. Если программа не ответила вовремя или завершилась, запрос завершается ошибкой,
+// а к следующему запросу программа запускается заново.
+type ProcessEnricher struct {
+	cfg ProcessConfig
+
+	slots chan struct{} // ограничивает число одновременных запросов
+	idle  chan *process // запущенные программы, ожидающие запроса (KeepAlive)
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func NewProcessEnricher(cfg ProcessConfig) (*ProcessEnricher, error) {
+	if cfg.Name == "" || cfg.Command == "" {
+		return nil, errors.New("process enricher: name and command are required")
+	}
+	if len(cfg.Fields) == 0 {
+		return nil, fmt.Errorf("process enricher %s: no fields", cfg.Name)
+	}
+	for _, f := range cfg.Fields {
+		if !slices.Contains(processFields, f) {
+			return nil, fmt.Errorf("process enricher %s: unsupported field %q", cfg.Name, f)
+		}
+	}
+	if cfg.MaxConcurrency <= 0 {
+		cfg.MaxConcurrency = 1
+	}
+
+	return &ProcessEnricher{
+		cfg:   cfg,
+		slots: make(chan struct{}, cfg.MaxConcurrency),
+		idle:  make(chan *process, cfg.MaxConcurrency),
+	}, nil
+}
+
+func (e *ProcessEnricher) Name() string {
+	return e.cfg.Name
+}
+
+func (e *ProcessEnricher) Fields() []string {
+	return e.cfg.Fields
+}
+
+func (e *ProcessEnricher) Requires() []string {
+	return e.cfg.Requires
+}
+
+// Provider возвращает имя обогатителя: у программы нет другого имени источника.
+func (e *ProcessEnricher) Provider() string {
+	return e.cfg.Name
+}
+
+func (e *ProcessEnricher) Enrich(ctx context.Context, user *model.User) error {
+	select {
+	case e.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-e.slots }()
+
+	if e.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.cfg.Timeout)
+		defer cancel()
+	}
+
+	req, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+
+	var res []byte
+	if e.cfg.KeepAlive {
+		res, err = e.call(ctx, req)
+	} else {
+		res, err = e.run(ctx, req)
+	}
+	if err != nil {
+		return err
+	}
+
+	return e.merge(user, bytes.TrimSpace(res))
+}
+
+// run запускает программу для одного запроса req и возвращает её ответ.
+func (e *ProcessEnricher) run(ctx context.Context, req []byte) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, e.cfg.Command, e.cfg.Args...)
+	cmd.Stdin = bytes.NewReader(req)
+	stderr := &stderrTail{}
+	cmd.Stderr = stderr
+	cmd.WaitDelay = time.Second
+
+	res, err := cmd.Output()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("%s: %w", e.cfg.Name, ctx.Err())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w%s", e.cfg.Name, err, stderr)
+	}
+	return res, nil
+}
+
+// call передаёт запрос req запущенной программе и возвращает её ответ. Свободная программа
+// берётся из e.idle, а если её нет, запускается новая.
+func (e *ProcessEnricher) call(ctx context.Context, req []byte) ([]byte, error) {
+	var p *process
+	select {
+	case p = <-e.idle:
+	default:
+		var err error
+		if p, err = startProcess(e.cfg.Command, e.cfg.Args); err != nil {
+			return nil, fmt.Errorf("%s: %w", e.cfg.Name, err)
+		}
+	}
+
+	res, err := p.call(ctx, req)
+	if err != nil {
+		// программа могла остаться посреди ответа, поэтому она больше не используется
+		p.stop()
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%s: %w", e.cfg.Name, ctx.Err())
+		}
+		return nil, fmt.Errorf("%s: %w%s", e.cfg.Name, err, p.stderr)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		p.stop()
+	} else {
+		e.idle <- p
+	}
+	return res, nil
+}
+
+// merge переносит в пользователя поля из ответа программы res.
+func (e *ProcessEnricher) merge(user *model.User, res []byte) error {
+	var present map[string]json.RawMessage
+	if err := json.Unmarshal(res, &present); err != nil {
+		return fmt.Errorf("%s: malformed response: %w", e.cfg.Name, err)
+	}
+
+	var result model.User
+	if err := json.Unmarshal(res, &result); err != nil {
+		return fmt.Errorf("%s: malformed response: %w", e.cfg.Name, err)
+	}
+
+	var fields []string
+	for _, f := range e.cfg.Fields {
+		if v, ok := present[f]; ok && string(v) != "null" {
+			fields = append(fields, f)
+		}
+	}
+	if len(fields) == 0 {
+		return ErrNoData
+	}
+
+	// происхождение и расхождения определяет сервис, а не программа
+	result.Provenance, result.Conflicts = nil, nil
+	copyFields(user, &result, fields)
+	for _, f := range fields {
+		user.SetProvenance(f, processProvenance(e.cfg.Name, res))
+	}
+	return nil
+}
+
+// Close останавливает программы, запущенные в режиме KeepAlive.
+func (e *ProcessEnricher) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.closed = true
+	for {
+		select {
+		case p := <-e.idle:
+			p.stop()
+		default:
+			return
+		}
+	}
+}
+
+// processProvenance возвращает происхождение значения, полученного от программы name в ответе res.
+func processProvenance(name string, res []byte) model.Provenance {
+	if len(res) > maxRawProvenance {
+		res = res[:maxRawProvenance]
+	}
+	return model.Provenance{
+		Source:    model.SourceEnricher,
+		Provider:  name,
+		Raw:       strings.ToValidUTF8(string(res), ""),
+		UpdatedAt: time.Now(),
+	}
+}
+
+// process - программа, запущенная в режиме KeepAlive.
+type process struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr *stderrTail
+}
+
+func startProcess(command string, args []string) (*process, error) {
+	cmd := exec.Command(command, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	p := &process{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout), stderr: &stderrTail{}}
+	cmd.Stderr = p.stderr
+	cmd.WaitDelay = time.Second
+
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// call отправляет программе строку req и читает строку ответа.
+// Если ctx завершится раньше, программа останавливается.
+func (p *process) call(ctx context.Context, req []byte) ([]byte, error) {
+	type result struct {
+		res []byte
+		err error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		if _, err := p.stdin.Write(append(req, '\n')); err != nil {
+			done <- result{err: err}
+			return
+		}
+		res, err := p.stdout.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			err = errors.New("process exited")
+		}
+		done <- result{res, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.res, r.err
+	case <-ctx.Done():
+		p.cmd.Process.Kill()
+		<-done
+		return nil, ctx.Err()
+	}
+}
+
+// stop завершает программу.
+func (p *process) stop() {
+	p.stdin.Close()
+	p.cmd.Process.Kill()
+	p.cmd.Wait()
+}
+
+// maxStderr - сколько последних байт stderr программы попадает в текст ошибки.
+const maxStderr = 512
+
+// stderrTail хранит последние байты, которые программа вывела в stderr.
+type stderrTail struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (t *stderrTail) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.buf = append(t.buf, b...)
+	if len(t.buf) > maxStderr {
+		t.buf = t.buf[len(t.buf)-maxStderr:]
+	}
+	return len(b), nil
+}
+
+// String возвращает вывод программы для добавления к тексту ошибки или пустую строку, если вывода не было.
+func (t *stderrTail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := strings.TrimSpace(strings.ToValidUTF8(string(t.buf), ""))
+	if s == "" {
+		return ""
+	}
+	return ": " + s
+}
@@ -0,0 +1,194 @@
+package enricher
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aachex/service/internal/model"
+)
+
+// TestHelperProcess - не настоящий тест: тесты ProcessEnricher запускают тестовый бинарник
+// как внешнюю программу. Режим работы - once или keepalive - передаётся последним аргументом.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	calls := 0
+	respond := func(req []byte) {
+		var u model.User
+		if err := json.Unmarshal(req, &u); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+
+		calls++
+		switch u.Name {
+		case "Anna":
+			// age_count показывает, сколько запросов обработала программа
+			fmt.Printf(`{"age": 30, "age_count": %d, "gender": "female", "gender_probability": 0.9}`+"\n", calls)
+		case "Sleepy":
+			time.Sleep(time.Minute)
+		case "Broken":
+			fmt.Fprintln(os.Stderr, "lookup failed")
+			os.Exit(1)
+		default:
+			fmt.Println(`{"name": "ignored"}`)
+		}
+	}
+
+	switch os.Args[len(os.Args)-1] {
+	case "once":
+		req, _ := io.ReadAll(os.Stdin)
+		respond(req)
+	case "keepalive":
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			respond(scanner.Bytes())
+		}
+	}
+}
+
+func newHelperEnricher(t *testing.T, mode string, cfg ProcessConfig) *ProcessEnricher {
+	t.Setenv("GO_WANT_HELPER_PROCESS", "1")
+
+	cfg.Name = "hr"
+	cfg.Command = os.Args[0]
+	cfg.Args = []string{"-test.run=^TestHelperProcess$", "--", mode}
+	cfg.Fields = []string{"age", "gender"}
+	e, err := NewProcessEnricher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(e.Close)
+	return e
+}
+
+func TestProcessEnricher(t *testing.T) {
+	e := newHelperEnricher(t, "once", ProcessConfig{})
+
+	u := model.User{Name: "Anna", Gender: "male"}
+	if err := e.Enrich(t.Context(), &u); err != nil {
+		t.Fatal(err)
+	}
+	if u.Age != 30 || u.Gender != "female" || u.GenderProbability != 0.9 {
+		t.Errorf("response wasn't merged: %+v", u)
+	}
+	if p := u.Provenance["gender"]; p.Provider != "hr" || !strings.Contains(p.Raw, "female") {
+		t.Errorf("unexpected provenance %+v", p)
+	}
+
+	// поля, которые не заполняет обогатитель, не переносятся
+	u = model.User{Name: "Boris"}
+	if err := e.Enrich(t.Context(), &u); !errors.Is(err, ErrNoData) {
+		t.Errorf("wanted ErrNoData, got %v", err)
+	}
+	if u.Name != "Boris" {
+		t.Errorf("name was overwritten: %s", u.Name)
+	}
+}
+
+func TestProcessEnricherKeepAlive(t *testing.T) {
+	e := newHelperEnricher(t, "keepalive", ProcessConfig{KeepAlive: true})
+
+	for i := 1; i <= 3; i++ {
+		u := model.User{Name: "Anna"}
+		if err := e.Enrich(t.Context(), &u); err != nil {
+			t.Fatal(err)
+		}
+		if u.AgeCount != i {
+			t.Errorf("request %d was handled by a new process (call %d)", i, u.AgeCount)
+		}
+	}
+}
+
+func TestProcessEnricherConcurrency(t *testing.T) {
+	e := newHelperEnricher(t, "keepalive", ProcessConfig{KeepAlive: true, MaxConcurrency: 1})
+
+	// с одной программой запросы выполняются по очереди, и она видит их все
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		calls []int
+	)
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			u := model.User{Name: "Anna"}
+			if err := e.Enrich(t.Context(), &u); err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			calls = append(calls, u.AgeCount)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	slices.Sort(calls)
+	if !slices.Equal(calls, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("wanted calls 1..5 of a single process, got %v", calls)
+	}
+}
+
+func TestProcessEnricherTimeout(t *testing.T) {
+	for _, keepAlive := range []bool{false, true} {
+		mode := "once"
+		if keepAlive {
+			mode = "keepalive"
+		}
+		e := newHelperEnricher(t, mode, ProcessConfig{KeepAlive: keepAlive, Timeout: 2 * time.Second})
+
+		start := time.Now()
+		u := model.User{Name: "Sleepy"}
+		if err := e.Enrich(t.Context(), &u); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: wanted deadline exceeded, got %v", mode, err)
+		}
+		if elapsed := time.Since(start); elapsed > 10*time.Second {
+			t.Errorf("%s: process wasn't stopped on timeout, took %v", mode, elapsed)
+		}
+
+		// после таймаута программа перезапускается
+		u = model.User{Name: "Anna"}
+		if err := e.Enrich(t.Context(), &u); err != nil {
+			t.Errorf("%s: %v", mode, err)
+		}
+	}
+}
+
+func TestProcessEnricherFailure(t *testing.T) {
+	for _, keepAlive := range []bool{false, true} {
+		mode := "once"
+		if keepAlive {
+			mode = "keepalive"
+		}
+		e := newHelperEnricher(t, mode, ProcessConfig{KeepAlive: keepAlive})
+
+		u := model.User{Name: "Broken"}
+		err := e.Enrich(t.Context(), &u)
+		if err == nil || !strings.Contains(err.Error(), "lookup failed") {
+			t.Errorf("%s: wanted error with stderr, got %v", mode, err)
+		}
+	}
+}
+
+func TestProcessConfig(t *testing.T) {
+	_, err := NewProcessEnricher(ProcessConfig{Name: "hr", Command: "hr.py", Fields: []string{"salary"}})
+	if err == nil {
+		t.Error("unsupported field was accepted")
+	}
+}
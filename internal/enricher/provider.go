@@ -0,0 +1,262 @@
+package enricher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aachex/service/internal/model"
+)
+
+// Адреса публичных API, которые используются, если в ProviderConfig не задан BaseURL.
+const (
+	AgifyURL       = "https://api.agify.io/"
+	GenderizeURL   = "https://api.genderize.io/"
+	NationalizeURL = "https://api.nationalize.io/"
+)
+
+// ProviderConfig - настройки обращения к внешнему API.
+type ProviderConfig struct {
+	BaseURL   string        // адрес API; позволяет направить запросы на локальную заглушку или прокси
+	APIKey    string        // ключ платного тарифа, передаётся в параметре apikey
+	Timeout   time.Duration // ограничение на один запрос; нулевое значение - без ограничения
+	UserAgent string
+
+	// DailyQuota - суточная квота запросов, если она известна заранее. Без неё квота
+	// узнаётся из заголовков X-Rate-Limit-* в ответах провайдера.
+	DailyQuota int
+
+	// Localize включает уточнение запроса страной пользователя (параметр country_id).
+	// Поддерживается agify и genderize.
+	Localize bool
+
+	// Ответы с вероятностью ниже MinProbability или основанные на меньшем, чем MinCount,
+	// количестве записей не используются, и поле остаётся пустым.
+	MinProbability float64
+	MinCount       int
+}
+
+// StatusError - ответ провайдера с кодом, отличным от 2xx.
+type StatusError struct {
+	Provider   string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: unexpected status %d %s", e.Provider, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// ProvidersConfig - настройки встроенных провайдеров.
+type ProvidersConfig struct {
+	Agify       ProviderConfig
+	Genderize   ProviderConfig
+	Nationalize ProviderConfig
+}
+
+// provider - общая часть обогатителей, которые обращаются к внешним API.
+type provider struct {
+	name    string // имя внешнего сервиса, например agify
+	cfg     ProviderConfig
+	client  *http.Client
+	cache   *Cache
+	breaker *Breaker
+}
+
+// ProviderOption настраивает обогатитель, обращающийся к внешнему API.
+type ProviderOption func(p *provider)
+
+// WithCache включает кэширование ответов внешнего API в cache.
+func WithCache(cache *Cache) ProviderOption {
+	return func(p *provider) {
+		p.cache = cache
+	}
+}
+
+// WithHTTPClient задаёт HTTP-клиент для запросов к внешнему API. По умолчанию используется http.DefaultClient.
+func WithHTTPClient(client *http.Client) ProviderOption {
+	return func(p *provider) {
+		p.client = client
+	}
+}
+
+// WithBreaker включает учёт квоты провайдера и отключение провайдера, когда квота исчерпана
+// или он раз за разом отвечает ошибками.
+func WithBreaker(cfg BreakerConfig) ProviderOption {
+	return func(p *provider) {
+		p.breaker = NewBreaker(p.name, p.cfg.DailyQuota, cfg)
+	}
+}
+
+// Breaker возвращает автомат защиты провайдера или nil, если он не включён.
+func (p provider) Breaker() *Breaker {
+	return p.breaker
+}
+
+func newProvider(name, defaultURL string, cfg ProviderConfig, opts []ProviderOption) provider {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultURL
+	}
+
+	p := provider{name: name, cfg: cfg, client: http.DefaultClient}
+	for _, opt := range opts {
+		opt(&p)
+	}
+	return p
+}
+
+// Provider возвращает имя внешнего сервиса.
+func (p provider) Provider() string {
+	return p.name
+}
+
+// maxRawProvenance - сколько байт ответа провайдера сохраняется в происхождении значения.
+const maxRawProvenance = 512
+
+// provenance возвращает происхождение значения, определённого провайдером по ответу raw.
+func (p provider) provenance(raw []byte) model.Provenance {
+	if len(raw) > maxRawProvenance {
+		raw = raw[:maxRawProvenance]
+	}
+	return model.Provenance{
+		Source:    model.SourceEnricher,
+		Provider:  p.name,
+		Raw:       strings.ToValidUTF8(string(raw), ""),
+		UpdatedAt: time.Now(),
+	}
+}
+
+// confident проверяет, достаточно ли уверен провайдер в ответе с вероятностью probability,
+// основанном на count записях, и возвращает ErrNoData, если нет.
+// Для провайдеров, которые не сообщают вероятность, probability равна 1.
+func (p provider) confident(probability float64, count int) error {
+	if probability < p.cfg.MinProbability || count < p.cfg.MinCount {
+		return fmt.Errorf("%w: below confidence threshold (probability %.2f, count %d)", ErrNoData, probability, count)
+	}
+	return nil
+}
+
+// lookupLocalized запрашивает данные по имени name с учётом страны country, если она известна
+// и локализация включена. Если для страны данных нет, данные запрашиваются без её учёта.
+func lookupLocalized[T any](ctx context.Context, p provider, name, country string, found func(T) bool) (body T, raw []byte, ok bool, err error) {
+	if p.cfg.Localize && country != "" {
+		body, raw, ok, err = lookup(ctx, p, name, country, found)
+		if err != nil || ok {
+			return body, raw, ok, err
+		}
+	}
+
+	return lookup(ctx, p, name, "", found)
+}
+
+// lookup запрашивает у провайдера p данные по имени name среди жителей страны country или, если country пуста,
+// среди всех. found сообщает, есть ли у провайдера данные для этого имени: ответ, для которого found вернул false,
+// считается отсутствием данных. Если у провайдера есть кэш, ответы, в том числе об отсутствии данных,
+// берутся из него и сохраняются в него. raw - тело ответа провайдера.
+func lookup[T any](ctx context.Context, p provider, name, country string, found func(T) bool) (body T, raw []byte, ok bool, err error) {
+	key := cacheKey(p.name, name, country)
+
+	if p.cache != nil {
+		b, hit := p.cache.Get(ctx, key)
+		if hit && len(b) == 0 {
+			return body, nil, false, nil
+		}
+		if hit && json.Unmarshal(b, &body) == nil {
+			return body, b, true, nil
+		}
+	}
+
+	if p.breaker != nil {
+		if err = p.breaker.Allow(ctx); err != nil {
+			return body, nil, false, err
+		}
+	}
+
+	params := url.Values{"name": {name}}
+	if country != "" {
+		params.Set("country_id", country)
+	}
+
+	b, quota, err := p.get(ctx, params)
+	if p.breaker != nil {
+		// квота сохраняется и после того, как истёк срок запроса
+		storeCtx := context.WithoutCancel(ctx)
+		switch {
+		case errors.Is(ctx.Err(), context.Canceled):
+			p.breaker.Cancel()
+		case err != nil:
+			// провайдер, который не успел ответить за отведённое время, считается отказавшим
+			p.breaker.Failure(storeCtx, err, quota)
+		default:
+			p.breaker.Success(storeCtx, quota)
+		}
+	}
+	if err != nil {
+		return body, nil, false, err
+	}
+
+	err = json.Unmarshal(b, &body)
+	if err != nil {
+		return body, nil, false, err
+	}
+
+	ok = found(body)
+	if !ok {
+		b = nil
+	}
+	if p.cache != nil {
+		p.cache.Set(ctx, key, b)
+	}
+	return body, b, ok, nil
+}
+
+// get выполняет запрос к API провайдера с параметрами params и возвращает тело ответа
+// и квоту из заголовков ответа, если они есть.
+func (p provider) get(ctx context.Context, params url.Values) ([]byte, *Quota, error) {
+	if p.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.cfg.Timeout)
+		defer cancel()
+	}
+
+	u, err := url.Parse(p.cfg.BaseURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", p.name, err)
+	}
+
+	if p.cfg.APIKey != "" {
+		params.Set("apikey", p.cfg.APIKey)
+	}
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.cfg.UserAgent != "" {
+		req.Header.Set("User-Agent", p.cfg.UserAgent)
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	quota := quotaFromHeaders(res.Header, time.Now())
+
+	switch {
+	case res.StatusCode == http.StatusTooManyRequests:
+		return nil, quota, fmt.Errorf("%s: %w", p.name, ErrRateLimited)
+	case res.StatusCode < 200 || res.StatusCode > 299:
+		return nil, quota, &StatusError{Provider: p.name, StatusCode: res.StatusCode}
+	}
+
+	b, err := io.ReadAll(res.Body)
+	return b, quota, err
+}
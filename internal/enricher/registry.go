@@ -0,0 +1,121 @@
+package enricher
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var (
+	ErrUnknownEnricher   = errors.New("unknown enricher")
+	ErrDuplicateEnricher = errors.New("duplicate enricher")
+	ErrFieldOverlap      = errors.New("enrichers fill the same field")
+)
+
+// Registry хранит доступные обогатители в порядке их регистрации.
+type Registry struct {
+	order  []Enricher
+	byName map[string]Enricher
+	budget time.Duration
+}
+
+// NewRegistry создаёт реестр и регистрирует в нём переданные обогатители.
+func NewRegistry(enrichers ...Enricher) (*Registry, error) {
+	r := &Registry{byName: make(map[string]Enricher)}
+	for _, e := range enrichers {
+		if err := r.Register(e); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Register добавляет обогатитель в конец реестра. Обогатитель не может заполнять поле, которое уже заполняет
+// другой обогатитель реестра: в цепочке они работали бы одновременно, и в поле оставалось бы значение того,
+// кто ответил последним. Несколько источников одного поля объединяются через NewArbiter.
+func (r *Registry) Register(e Enricher) error {
+	if _, ok := r.byName[e.Name()]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateEnricher, e.Name())
+	}
+	for _, other := range r.order {
+		if field, ok := Overlap(e, other); ok {
+			return fmt.Errorf("%w: %s and %s fill %s", ErrFieldOverlap, e.Name(), other.Name(), field)
+		}
+	}
+
+	r.byName[e.Name()] = e
+	r.order = append(r.order, e)
+	return nil
+}
+
+// Overlap возвращает первое поле a, которое заполняет и b.
+func Overlap(a, b Enricher) (string, bool) {
+	fields := b.Fields()
+	for _, f := range a.Fields() {
+		if slices.Contains(fields, f) {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+// SetBudget задаёт общее время, которое цепочки из реестра могут потратить на обогащение.
+// Нулевое значение снимает ограничение.
+func (r *Registry) SetBudget(budget time.Duration) {
+	r.budget = budget
+}
+
+// Get возвращает обогатитель по имени.
+func (r *Registry) Get(name string) (Enricher, error) {
+	e, ok := r.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEnricher, name)
+	}
+	return e, nil
+}
+
+// Names возвращает имена зарегистрированных обогатителей.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.order))
+	for _, e := range r.order {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+// Omit возвращает имена обогатителей из names, кроме тех, что заполняют какое-либо из полей fields.
+// Пустой names означает все зарегистрированные обогатители. Неизвестные имена остаются в списке.
+func (r *Registry) Omit(names []string, fields ...string) []string {
+	if len(names) == 0 {
+		names = r.Names()
+	}
+
+	kept := make([]string, 0, len(names))
+	for _, name := range names {
+		e, ok := r.byName[name]
+		if ok && slices.ContainsFunc(e.Fields(), func(f string) bool { return slices.Contains(fields, f) }) {
+			continue
+		}
+		kept = append(kept, name)
+	}
+	return kept
+}
+
+// Select возвращает цепочку из обогатителей с указанными именами в указанном порядке.
+// Если имена не указаны, в цепочку попадают все зарегистрированные обогатители.
+func (r *Registry) Select(names ...string) (Enricher, error) {
+	if len(names) == 0 {
+		return NewChain(r.budget, r.order...), nil
+	}
+
+	enrichers := make([]Enricher, 0, len(names))
+	for _, name := range names {
+		e, err := r.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		enrichers = append(enrichers, e)
+	}
+	return NewChain(r.budget, enrichers...), nil
+}
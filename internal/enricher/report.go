@@ -0,0 +1,131 @@
+package enricher
+
+import (
+	"errors"
+)
+
+// ErrNoData возвращается обогатителем, когда источник ничего не знает о пользователе
+// или не уверен в ответе. Это не сбой: повторять обогащение бессмысленно.
+var ErrNoData = errors.New("no data")
+
+// ErrLocked возвращается цепочкой вместо запуска обогатителя, когда значение его поля заблокировано
+// (см. model.Provenance.Locked). Такие значения обогатители не перезаписывают.
+var ErrLocked = errors.New("field is locked")
+
+// Итоги обогащения поля.
+const (
+	StatusOK            = "ok"             // поле заполнено
+	StatusNoData        = "no_data"        // источник не знает значения поля
+	StatusProviderError = "provider_error" // источник ответил ошибкой или не успел ответить
+	StatusSkipped       = "skipped"        // к источнику не обращались из-за исчерпанной квоты или блокировки поля
+)
+
+// Outcome - итог обогащения одного поля.
+type Outcome struct {
+	Status   string `json:"status" enums:"ok,no_data,provider_error,skipped"`
+	Provider string `json:"provider,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+
+	failed bool
+}
+
+// Failed сообщает, что поле не заполнено из-за сбоя, и обогащение стоит повторить позже.
+func (o Outcome) Failed() bool {
+	return o.failed
+}
+
+// Report - итоги обогащения по полям пользователя.
+type Report map[string]Outcome
+
+// Failed сообщает, что хотя бы одно поле не заполнено из-за сбоя.
+func (r Report) Failed() bool {
+	for _, o := range r {
+		if o.Failed() {
+			return true
+		}
+	}
+	return false
+}
+
+// EnricherError - ошибка одного из обогатителей цепочки.
+type EnricherError struct {
+	Enricher Enricher
+	Err      error
+}
+
+func (e *EnricherError) Error() string {
+	return e.Enricher.Name() + ": " + e.Err.Error()
+}
+
+func (e *EnricherError) Unwrap() error {
+	return e.Err
+}
+
+// NewReport составляет итоги обогащения обогатителем e, завершившегося ошибкой err.
+// Для цепочки итоги составляются по каждому её обогатителю отдельно.
+func NewReport(e Enricher, err error) Report {
+	report := make(Report)
+
+	members := []Enricher{e}
+	if c, ok := e.(*Chain); ok {
+		members = c.enrichers
+	}
+	for _, m := range members {
+		setOutcome(report, m, nil)
+	}
+
+	var chainErrs []*EnricherError
+	collectEnricherErrors(err, &chainErrs)
+	if len(chainErrs) == 0 && err != nil {
+		chainErrs = append(chainErrs, &EnricherError{Enricher: e, Err: err})
+	}
+	for _, ce := range chainErrs {
+		setOutcome(report, ce.Enricher, ce.Err)
+	}
+	return report
+}
+
+// setOutcome записывает в report итог обогатителя e по каждому его полю.
+func setOutcome(report Report, e Enricher, err error) {
+	o := Outcome{Status: StatusOK, Provider: providerName(e)}
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrNoData):
+		o.Status = StatusNoData
+	case errors.Is(err, ErrLocked):
+		o.Status = StatusSkipped
+	case errors.Is(err, ErrProviderUnavailable):
+		o.Status = StatusSkipped
+		o.failed = true
+	default:
+		o.Status = StatusProviderError
+		o.failed = true
+	}
+	if err != nil {
+		o.Reason = err.Error()
+	}
+
+	for _, field := range e.Fields() {
+		report[field] = o
+	}
+}
+
+// collectEnricherErrors собирает ошибки обогатителей из дерева ошибок err.
+func collectEnricherErrors(err error, dst *[]*EnricherError) {
+	switch err := err.(type) {
+	case *EnricherError:
+		*dst = append(*dst, err)
+	case interface{ Unwrap() []error }:
+		for _, e := range err.Unwrap() {
+			collectEnricherErrors(e, dst)
+		}
+	}
+}
+
+// providerName возвращает имя внешнего сервиса обогатителя или его имя, если сервис неизвестен.
+func providerName(e Enricher) string {
+	if p, ok := e.(interface{ Provider() string }); ok {
+		return p.Provider()
+	}
+	return e.Name()
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// Candidate - значение поля, предложенное одним из источников.
+type Candidate struct {
+	Source      string  `json:"source"`
+	Value       string  `json:"value"`
+	Probability float64 `json:"probability"`
+}
+
+// EnrichmentConflict - расхождение источников в значении поля пользователя и выбранное значение.
+type EnrichmentConflict struct {
+	Id         int64       `json:"id,omitempty"`
+	UserId     int64       `json:"user_id,omitempty"`
+	Field      string      `json:"field"`
+	Strategy   string      `json:"strategy"`
+	Chosen     string      `json:"chosen"`
+	Candidates []Candidate `json:"candidates"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package model
+
+// Nationality - страна, к которой может относиться пользователь, и вероятность этого.
+type Nationality struct {
+	Country     string  `json:"country_id"`
+	Probability float64 `json:"probability"`
+}
+
+// Enrichment - результаты обогащения пользователя, которые нужно сохранить.
+type Enrichment struct {
+	Values     map[string]any        // новые значения столбцов таблицы users
+	Provenance map[string]Provenance // происхождение новых значений по именам полей
+	Conflicts  []EnrichmentConflict  // расхождения источников
+
+	// Nationalities - все страны-кандидаты по убыванию вероятности. nil, если национальность не определялась.
+	Nationalities []Nationality
+}
@@ -0,0 +1,10 @@
+package model
+
+import "errors"
+
+// ErrInvalidField - в фильтре или обновлении указано поле, которого нет, которое нельзя использовать
+// таким образом или значение которого имеет неподходящий тип. Это ошибка клиента, а не хранилища.
+var ErrInvalidField = errors.New("invalid field")
+
+// ErrNotFound - пользователя нет или он удалён.
+var ErrNotFound = errors.New("user not found")
@@ -0,0 +1,12 @@
+package model
+
+// FacetUnknown - ключ фасета для пользователей, у которых поле не заполнено.
+const FacetUnknown = "unknown"
+
+// Facets - количество пользователей, подходящих под фильтр, по значениям полей.
+// Возраст группируется по диапазонам, например "25-34" или "65+".
+type Facets struct {
+	Gender      map[string]int `json:"gender"`
+	Nationality map[string]int `json:"nationality"`
+	Age         map[string]int `json:"age"`
+}
@@ -0,0 +1,124 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Операторы сравнения поля со значением в Filter.
+const (
+	OpEq       = "eq"       // равно; с null - поле пустое
+	OpNe       = "ne"       // не равно, в том числе пустое поле; с null - поле не пустое
+	OpGt       = "gt"       // больше
+	OpGte      = "gte"      // больше или равно
+	OpLt       = "lt"       // меньше
+	OpLte      = "lte"      // меньше или равно
+	OpIn       = "in"       // равно одному из значений списка
+	OpNotIn    = "not_in"   // не равно ни одному из значений списка
+	OpPrefix   = "prefix"   // строка начинается с значения
+	OpContains = "contains" // строка содержит значение
+	OpILike    = "ilike"    // строка соответствует шаблону LIKE без учёта регистра
+	OpIsNull   = "is_null"  // поле пустое; со значением false - не пустое
+)
+
+// Filter - условие отбора пользователей. В условии задаётся ровно одно из:
+//   - сравнение поля Field со значением Value оператором Op (по умолчанию eq);
+//   - группа And, в которой должны выполняться все условия, или группа Or - хотя бы одно;
+//   - отрицание Not;
+//   - отбор по странам-кандидатам Candidate.
+//
+// Пустой фильтр отбирает всех пользователей.
+type Filter struct {
+	Field string `json:"field,omitempty"`
+	Op    string `json:"op,omitempty" enums:"eq,ne,gt,gte,lt,lte,in,not_in,prefix,contains,ilike,is_null"`
+	Value any    `json:"value,omitempty"`
+
+	And       []Filter         `json:"and,omitempty"`
+	Or        []Filter         `json:"or,omitempty"`
+	Not       *Filter          `json:"not,omitempty"`
+	Candidate *CandidateFilter `json:"candidate,omitempty"`
+}
+
+// CandidateFilter отбирает пользователей, среди стран-кандидатов которых есть одна из Countries
+// с вероятностью не ниже MinProbability.
+type CandidateFilter struct {
+	Countries      []string `json:"countries"`
+	MinProbability float64  `json:"min_probability,omitempty"`
+}
+
+// filterKeys - ключи, по которым Filter отличается от фильтра в прежнем формате.
+var filterKeys = []string{"field", "op", "value", "and", "or", "not", "candidate"}
+
+// UnmarshalJSON читает фильтр как в формате Filter, так и в прежнем формате {"поле": [значения]},
+// который переводится в Filter функцией FilterFromMap.
+func (f *Filter) UnmarshalJSON(b []byte) error {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return fmt.Errorf("%w: filter must be an object: %v", ErrInvalidField, err)
+	}
+
+	if slices.ContainsFunc(filterKeys, func(k string) bool { _, ok := keys[k]; return ok }) {
+		// отдельный тип без UnmarshalJSON, чтобы не уйти в рекурсию
+		type filter Filter
+		return json.Unmarshal(b, (*filter)(f))
+	}
+
+	var legacy map[string][]any
+	if err := json.Unmarshal(b, &legacy); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidField, err)
+	}
+	parsed, err := FilterFromMap(legacy)
+	if err != nil {
+		return err
+	}
+	*f = parsed
+	return nil
+}
+
+// FilterFromMap переводит фильтр в прежнем формате в Filter. Ключ - имя поля, а значения
+// объединяются через OR; разные поля объединяются через AND. Ключи вида min_<поле> задают минимальную
+// уверенность внешнего сервиса, например min_gender_probability, и остаются в Field как есть: какие поля
+// означают уверенность, проверяет хранилище. nationality_candidate и min_candidate_probability - отбор по странам-кандидатам.
+func FilterFromMap(m map[string][]any) (Filter, error) {
+	var f Filter
+
+	if countries := m["nationality_candidate"]; len(countries) > 0 {
+		c := &CandidateFilter{}
+		for _, v := range countries {
+			s, ok := v.(string)
+			if !ok {
+				return f, fmt.Errorf("%w %q: expected string, got %T", ErrInvalidField, "nationality_candidate", v)
+			}
+			c.Countries = append(c.Countries, s)
+		}
+		if p := m["min_candidate_probability"]; len(p) > 0 {
+			n, ok := p[0].(float64)
+			if !ok {
+				return f, fmt.Errorf("%w %q: expected number, got %T", ErrInvalidField, "min_candidate_probability", p[0])
+			}
+			c.MinProbability = n
+		}
+		f.And = append(f.And, Filter{Candidate: c})
+	}
+
+	// порядок условий не зависит от порядка обхода мапы
+	for _, key := range slices.Sorted(maps.Keys(m)) {
+		values := m[key]
+		if key == "" || len(values) == 0 || key == "nationality_candidate" || key == "min_candidate_probability" {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(key, "min_"):
+			f.And = append(f.And, Filter{Field: key, Op: OpGte, Value: values[0]})
+		case len(values) == 1:
+			f.And = append(f.And, Filter{Field: key, Op: OpEq, Value: values[0]})
+		default:
+			f.And = append(f.And, Filter{Field: key, Op: OpIn, Value: values})
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,9 @@
+package model
+
+// EnrichmentJob - задание на асинхронное обогащение пользователя.
+type EnrichmentJob struct {
+	Id        int64
+	UserId    int64
+	Enrichers []string // пустой список означает все обогатители
+	Attempts  int      // количество уже сделанных попыток
+}
@@ -0,0 +1,24 @@
+package model
+
+// Page - страница выборки: Limit записей, упорядоченных по Sort, начиная с Offset-й или,
+// если задан After, сразу после позиции After.
+type Page struct {
+	Offset int
+	Limit  int
+	Sort   []SortKey
+	After  *Keyset
+
+	// IncludeDeleted включает в выборку удалённых пользователей.
+	IncludeDeleted bool
+}
+
+// Keyset - позиция в выборке для постраничного чтения по ключу: значения ключей сортировки
+// последней записи предыдущей страницы. Последний ключ - id, чтобы позиция была однозначной.
+// В отличие от смещения позиция не сдвигается, когда в выборку добавляются новые записи.
+type Keyset struct {
+	Values []any `json:"values"`
+
+	// Sort - сортировка, для которой получена позиция, в формате ParseSort.
+	// С другой сортировкой позиция не имеет смысла.
+	Sort string `json:"sort"`
+}
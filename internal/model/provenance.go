@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// Источники значений полей пользователя.
+const (
+	SourceEnricher = "enricher" // значение определено внешним сервисом
+	SourceOperator = "operator" // значение задано через API
+	SourceImport   = "import"   // значение загружено из внешней системы
+)
+
+// Provenance - происхождение значения поля пользователя.
+type Provenance struct {
+	Source    string    `json:"source" enums:"enricher,operator,import"`
+	Provider  string    `json:"provider,omitempty"` // внешний сервис, если Source равен SourceEnricher
+	Raw       string    `json:"raw,omitempty"`      // начало ответа внешнего сервиса
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Locked запрещает обогатителям перезаписывать значение. Выставляется для значений,
+	// заданных вручную, и снимается явной разблокировкой поля.
+	Locked bool `json:"locked"`
+}
+
+// SetProvenance запоминает происхождение значения поля field.
+func (u *User) SetProvenance(field string, p Provenance) {
+	if u.Provenance == nil {
+		u.Provenance = make(map[string]Provenance)
+	}
+	u.Provenance[field] = p
+}
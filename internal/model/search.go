@@ -0,0 +1,19 @@
+package model
+
+// SearchResult - пользователь, найденный поиском по ФИО.
+type SearchResult struct {
+	User
+
+	// Score - насколько ФИО пользователя похоже на запрос: от 0 до 1.
+	Score float64 `json:"score"`
+
+	// Highlight - поля, в которых нашлись слова запроса, по их именам. Найденные слова обёрнуты в <mark>,
+	// остальной текст экранирован для вставки в HTML.
+	Highlight map[string]string `json:"highlight,omitempty"`
+}
+
+// Suggestion - подсказка при вводе: значение поля и число пользователей с этим значением.
+type Suggestion struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
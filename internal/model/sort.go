@@ -0,0 +1,83 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Расположение пустых значений при сортировке.
+const (
+	NullsFirst = "first"
+	NullsLast  = "last"
+)
+
+// SortKey - ключ сортировки выборки.
+type SortKey struct {
+	Field string
+	Desc  bool
+
+	// Nulls - где располагать пустые значения: NullsFirst, NullsLast или, если пусто, как принято в базе:
+	// в конце при сортировке по возрастанию и в начале при сортировке по убыванию.
+	Nulls string
+}
+
+// NullsFirst сообщает, идут ли пустые значения перед остальными.
+func (k SortKey) NullsFirst() bool {
+	if k.Nulls == "" {
+		return k.Desc
+	}
+	return k.Nulls == NullsFirst
+}
+
+// String возвращает ключ в формате ParseSort.
+func (k SortKey) String() string {
+	s := k.Field + ":asc"
+	if k.Desc {
+		s = k.Field + ":desc"
+	}
+	if k.Nulls != "" {
+		s += ":nulls_" + k.Nulls
+	}
+	return s
+}
+
+// ParseSort читает ключи сортировки из строки вида "surname,age:desc:nulls_last". Ключи перечисляются
+// через запятую в формате поле[:asc|desc][:nulls_first|nulls_last]; по умолчанию сортировка по возрастанию.
+// Имена полей здесь не проверяются: это делает хранилище.
+func ParseSort(s string) ([]SortKey, error) {
+	var keys []SortKey
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		parts := strings.Split(item, ":")
+		key := SortKey{Field: parts[0]}
+		for _, p := range parts[1:] {
+			switch p {
+			case "asc":
+				key.Desc = false
+			case "desc":
+				key.Desc = true
+			case "nulls_first":
+				key.Nulls = NullsFirst
+			case "nulls_last":
+				key.Nulls = NullsLast
+			default:
+				return nil, fmt.Errorf("%w %q: unknown sort option %q", ErrInvalidField, key.Field, p)
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// FormatSort возвращает ключи сортировки в формате ParseSort.
+func FormatSort(keys []SortKey) string {
+	items := make([]string, len(keys))
+	for i, k := range keys {
+		items[i] = k.String()
+	}
+	return strings.Join(items, ",")
+}
@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type User struct {
 	Id          int64  `json:"id"`
 	Name        string `json:"name"`
@@ -8,4 +10,29 @@ type User struct {
 	Age         int    `json:"age"`
 	Gender      string `json:"gender"`
 	Nationality string `json:"nationality"`
+
+	// Уверенность внешних сервисов в определённых ими значениях.
+	// Count - количество записей с таким именем, на которых основан ответ сервиса.
+	AgeCount               int     `json:"age_count"`
+	GenderProbability      float64 `json:"gender_probability"`
+	GenderCount            int     `json:"gender_count"`
+	NationalityProbability float64 `json:"nationality_probability"`
+	NationalityCount       int     `json:"nationality_count"`
+
+	// Nationalities - все страны, к которым может относиться пользователь, по убыванию вероятности.
+	// Заполняется при определении национальности и только по запросу при чтении.
+	Nationalities []Nationality `json:"nationalities,omitempty"`
+
+	// EnrichedAt - время последнего обогащения. nil, если пользователь ещё не обогащался.
+	EnrichedAt *time.Time `json:"enriched_at"`
+
+	// DeletedAt - время удаления. nil, если пользователь не удалён. Удалённого пользователя можно восстановить,
+	// пока он не очищен окончательно.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	// Provenance - происхождение значений полей по их именам. Заполняется только по запросу.
+	Provenance map[string]Provenance `json:"provenance,omitempty"`
+
+	// Conflicts - расхождения источников, найденные при последнем обогащении.
+	Conflicts []EnrichmentConflict `json:"conflicts,omitempty"`
 }
@@ -0,0 +1,69 @@
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ErrInvalidCursor - курсор повреждён, подделан или подписан другим ключом.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// CursorCodec превращает позицию в выборке в непрозрачный курсор и обратно.
+// Курсор подписывается HMAC-SHA256, поэтому клиент не может изменить позицию незаметно.
+type CursorCodec struct {
+	key []byte
+}
+
+// NewCursorCodec создаёт кодировщик курсоров с ключом подписи key. Если ключ пуст, генерируется случайный:
+// тогда курсоры перестают действовать после перезапуска сервиса.
+func NewCursorCodec(key []byte) *CursorCodec {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	return &CursorCodec{key: key}
+}
+
+// Encode возвращает курсор, указывающий на позицию v.
+func (c *CursorCodec) Encode(v any) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(c.sign(payload)), nil
+}
+
+// Decode проверяет подпись курсора cursor и читает из него позицию в v.
+func (c *CursorCodec) Decode(cursor string, v any) error {
+	enc := base64.RawURLEncoding
+
+	p, s, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return ErrInvalidCursor
+	}
+	payload, err := enc.DecodeString(p)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	sig, err := enc.DecodeString(s)
+	if err != nil || !hmac.Equal(sig, c.sign(payload)) {
+		return ErrInvalidCursor
+	}
+
+	if err = json.Unmarshal(payload, v); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+func (c *CursorCodec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,60 @@
+package pagination
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type position struct {
+	Id int64 `json:"id"`
+}
+
+func TestCursorCodec(t *testing.T) {
+	codec := NewCursorCodec([]byte("secret"))
+
+	cursor, err := codec.Encode(position{Id: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var p position
+	if err = codec.Decode(cursor, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Id != 42 {
+		t.Errorf("wanted id 42, got %d", p.Id)
+	}
+
+	// курсор с изменённой позицией или чужой подписью не принимается
+	payload, sig, _ := strings.Cut(cursor, ".")
+	forged, _ := codec.Encode(position{Id: 1})
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+
+	for _, c := range []string{
+		"",
+		payload,
+		forgedPayload + "." + sig,
+		payload + "." + sig[1:],
+		"!!!." + sig,
+	} {
+		if err = codec.Decode(c, &p); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("cursor %q: wanted ErrInvalidCursor, got %v", c, err)
+		}
+	}
+	if err = NewCursorCodec([]byte("other")).Decode(cursor, &p); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("cursor signed with another key was accepted: %v", err)
+	}
+}
+
+func TestCursorCodecRandomKey(t *testing.T) {
+	cursor, err := NewCursorCodec(nil).Encode(position{Id: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var p position
+	if err = NewCursorCodec(nil).Decode(cursor, &p); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("codecs with random keys accepted each other's cursors: %v", err)
+	}
+}
@@ -10,10 +10,17 @@ type CtxKey string
 
 func Middleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+		// со следующей страницы по курсору смещение не нужно
+		cursor := r.URL.Query().Get("cursor")
+
+		offset := 0
+		if cursor == "" || r.URL.Query().Has("offset") {
+			var err error
+			offset, err = strconv.Atoi(r.URL.Query().Get("offset"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
 		}
 
 		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
@@ -22,7 +29,12 @@ func Middleware(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), CtxKey("pagination"), Pagination{offset, limit})
+		if offset < 0 || limit < 0 {
+			http.Error(w, "offset and limit must not be negative", http.StatusBadRequest)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), CtxKey("pagination"), Pagination{offset, limit, cursor})
 
 		r = r.WithContext(ctx)
 
@@ -3,4 +3,7 @@ package pagination
 type Pagination struct {
 	Offset int
 	Limit  int
+
+	// Cursor - курсор следующей страницы из ответа на предыдущий запрос. Пустой, если страница задана смещением.
+	Cursor string
 }
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/aachex/service/internal/model"
+)
+
+// ConflictsRepository читает расхождения источников обогащения для разбора аналитиками.
+// Расхождения сохраняются вместе с результатами обогащения в UsersRepository.
+type ConflictsRepository struct {
+	db *sql.DB
+}
+
+func NewConflictsRepository(db *sql.DB) *ConflictsRepository {
+	return &ConflictsRepository{db: db}
+}
+
+// GetConflicts возвращает расхождения от новых к старым. Если field не пуст, возвращаются только расхождения в этом поле.
+func (r *ConflictsRepository) GetConflicts(ctx context.Context, field string, offset, limit int) ([]model.EnrichmentConflict, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id, field, strategy, chosen, candidates, created_at
+		FROM enrichment_conflicts
+		WHERE $1 = '' OR field = $1
+		ORDER BY created_at DESC, id DESC
+		OFFSET $2 LIMIT $3`,
+		field, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conflicts := make([]model.EnrichmentConflict, 0)
+	for rows.Next() {
+		var (
+			c          model.EnrichmentConflict
+			candidates []byte
+		)
+		err = rows.Scan(&c.Id, &c.UserId, &c.Field, &c.Strategy, &c.Chosen, &candidates, &c.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(candidates, &c.Candidates); err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, c)
+	}
+	return conflicts, rows.Err()
+}
+
+// saveConflicts сохраняет расхождения источников, найденные при обогащении пользователя uid.
+// Расхождение в поле заменяет ранее сохранённое расхождение пользователя в том же поле,
+// чтобы повторное обогащение не накапливало дубликаты.
+func saveConflicts(ctx context.Context, e execer, uid int64, conflicts []model.EnrichmentConflict) error {
+	for _, c := range conflicts {
+		candidates, err := json.Marshal(c.Candidates)
+		if err != nil {
+			return err
+		}
+
+		_, err = e.ExecContext(ctx, "DELETE FROM enrichment_conflicts WHERE user_id = $1 AND field = $2", uid, c.Field)
+		if err != nil {
+			return err
+		}
+
+		_, err = e.ExecContext(ctx, `
+			INSERT INTO enrichment_conflicts(user_id, field, strategy, chosen, candidates, created_at)
+			VALUES($1, $2, $3, $4, $5, $6)`,
+			uid, c.Field, c.Strategy, c.Chosen, candidates, c.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aachex/service/internal/model"
+)
+
+func TestSaveConflictsReplaces(t *testing.T) {
+	loadEnv(t)
+	db := openDb(t)
+
+	repo := NewUsersRepository(db)
+	id, err := repo.Create(t.Context(), mock.user())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Purge(t.Context(), id)
+
+	conflict := func(chosen string) model.EnrichmentConflict {
+		return model.EnrichmentConflict{
+			Field:    "gender",
+			Strategy: "confidence",
+			Chosen:   chosen,
+			Candidates: []model.Candidate{
+				{Source: "genderize", Value: "male", Probability: 0.6},
+				{Source: "dictionary", Value: "female", Probability: 0.55},
+			},
+			CreatedAt: time.Now(),
+		}
+	}
+
+	// повторное обогащение заменяет расхождение в поле, а не добавляет ещё одно
+	for _, chosen := range []string{"male", "female"} {
+		err = repo.SaveEnrichment(t.Context(), id, model.Enrichment{
+			Values:    map[string]any{"gender": chosen},
+			Conflicts: []model.EnrichmentConflict{conflict(chosen)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conflicts, err := NewConflictsRepository(db).GetConflicts(t.Context(), "gender", 0, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var own []model.EnrichmentConflict
+	for _, c := range conflicts {
+		if c.UserId == id {
+			own = append(own, c)
+		}
+	}
+	if len(own) != 1 || own[0].Chosen != "female" {
+		t.Errorf("wanted the latest conflict only, got %+v", own)
+	}
+}
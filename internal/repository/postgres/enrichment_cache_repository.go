@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// EnrichmentCacheRepository хранит кэш ответов внешних API обогащения.
+type EnrichmentCacheRepository struct {
+	db *sql.DB
+}
+
+func NewEnrichmentCacheRepository(db *sql.DB) *EnrichmentCacheRepository {
+	return &EnrichmentCacheRepository{db: db}
+}
+
+// GetCached возвращает значение по ключу, если срок его хранения ещё не истёк.
+func (r *EnrichmentCacheRepository) GetCached(ctx context.Context, key string) (value []byte, expiresAt time.Time, ok bool, err error) {
+	row := r.db.QueryRowContext(ctx,
+		"SELECT value, expires_at FROM enrichment_cache WHERE key = $1 AND expires_at > now()", key)
+
+	err = row.Scan(&value, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, expiresAt, false, nil
+	}
+	if err != nil {
+		return nil, expiresAt, false, err
+	}
+
+	return value, expiresAt, true, nil
+}
+
+// SetCached сохраняет значение по ключу до момента expiresAt, заменяя прежнее.
+func (r *EnrichmentCacheRepository) SetCached(ctx context.Context, key string, value []byte, expiresAt time.Time) error {
+	if value == nil {
+		value = []byte{}
+	}
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO enrichment_cache(key, value, expires_at) VALUES($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at`,
+		key, value, expiresAt)
+	return err
+}
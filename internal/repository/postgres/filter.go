@@ -0,0 +1,220 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aachex/service/internal/model"
+)
+
+// writeFilter добавляет к запросу условие f на пользователей из подзапроса u.
+func writeFilter(q *queryBuilder, f model.Filter) error {
+	kinds := 0
+	for _, set := range []bool{f.Field != "", f.And != nil, f.Or != nil, f.Not != nil, f.Candidate != nil} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds > 1 {
+		return fmt.Errorf("%w: filter must contain only one of field, and, or, not, candidate", model.ErrInvalidField)
+	}
+
+	switch {
+	case f.And != nil:
+		return writeGroup(q, f.And, " AND ", "true")
+	case f.Or != nil:
+		return writeGroup(q, f.Or, " OR ", "false")
+	case f.Not != nil:
+		q.write("NOT (")
+		if err := writeFilter(q, *f.Not); err != nil {
+			return err
+		}
+		q.write(")")
+		return nil
+	case f.Candidate != nil:
+		return writeCandidate(q, *f.Candidate)
+	case f.Field != "":
+		return writeComparison(q, f)
+	default:
+		q.write("true")
+		return nil
+	}
+}
+
+// writeGroup добавляет к запросу условия filters, объединённые оператором sep.
+// Пустая группа заменяется значением empty.
+func writeGroup(q *queryBuilder, filters []model.Filter, sep, empty string) error {
+	if len(filters) == 0 {
+		q.write(empty)
+		return nil
+	}
+
+	q.write("(")
+	for i, f := range filters {
+		if i > 0 {
+			q.write(sep)
+		}
+		if err := writeFilter(q, f); err != nil {
+			return err
+		}
+	}
+	q.write(")")
+	return nil
+}
+
+// writeCandidate добавляет к запросу условие на страны-кандидаты пользователя.
+func writeCandidate(q *queryBuilder, c model.CandidateFilter) error {
+	if len(c.Countries) == 0 {
+		return invalidField("candidate", "no countries")
+	}
+
+	q.write("EXISTS (SELECT 1 FROM user_nationalities n WHERE n.user_id = u.id AND n.country_id IN (")
+	for i, country := range c.Countries {
+		if i > 0 {
+			q.write(", ")
+		}
+		q.write(q.param(country))
+	}
+	q.write(")")
+	if c.MinProbability > 0 {
+		q.write(" AND n.probability >= " + q.param(c.MinProbability))
+	}
+	q.write(")")
+	return nil
+}
+
+// filterColumn возвращает столбец, с которым сравнивается поле фильтра f. Ключ прежнего формата min_<столбец>
+// задаёт минимум только для столбцов с уверенностью внешних сервисов.
+func filterColumn(f model.Filter) (model.Filter, column, error) {
+	if name, ok := strings.CutPrefix(f.Field, "min_"); ok && f.Op == model.OpGte {
+		if c, ok := usersTable[name]; ok && c.confidence {
+			f.Field = name
+			return f, c, nil
+		}
+	}
+
+	c, err := usersTable.filterable(f.Field)
+	return f, c, err
+}
+
+// writeComparison добавляет к запросу сравнение поля f.Field со значением f.Value оператором f.Op.
+func writeComparison(q *queryBuilder, f model.Filter) error {
+	f, c, err := filterColumn(f)
+	if err != nil {
+		return err
+	}
+	col := ident(f.Field)
+
+	op := f.Op
+	if op == "" {
+		op = model.OpEq
+	}
+
+	switch op {
+	case model.OpEq, model.OpNe:
+		v, err := c.value(f.Field, f.Value)
+		if err != nil {
+			return err
+		}
+		switch {
+		case v == nil && op == model.OpEq:
+			q.write(col + " IS NULL")
+		case v == nil:
+			q.write(col + " IS NOT NULL")
+		case op == model.OpEq:
+			q.write(col + " = " + q.param(v))
+		default:
+			// пустое поле тоже не равно значению
+			q.write(col + " IS DISTINCT FROM " + q.param(v))
+		}
+
+	case model.OpGt, model.OpGte, model.OpLt, model.OpLte:
+		if f.Value == nil {
+			return invalidField(f.Field, "operator %s needs a value", op)
+		}
+		v, err := c.value(f.Field, f.Value)
+		if err != nil {
+			return err
+		}
+		q.write(col + " " + comparisons[op] + " " + q.param(v))
+
+	case model.OpIn, model.OpNotIn:
+		list, ok := f.Value.([]any)
+		if !ok {
+			return invalidField(f.Field, "operator %s needs a list, got %T", op, f.Value)
+		}
+		if len(list) == 0 {
+			// ни одно значение не подходит для in, и любое подходит для not_in
+			if op == model.OpIn {
+				q.write("false")
+			} else {
+				q.write("true")
+			}
+			return nil
+		}
+
+		placeholders := make([]string, len(list))
+		for i, item := range list {
+			if item == nil {
+				return invalidField(f.Field, "null in list, use is_null")
+			}
+			v, err := c.value(f.Field, item)
+			if err != nil {
+				return err
+			}
+			placeholders[i] = q.param(v)
+		}
+		in := col + " IN (" + strings.Join(placeholders, ", ") + ")"
+		if op == model.OpIn {
+			q.write(in)
+		} else {
+			q.write("(" + col + " IS NULL OR NOT " + in + ")")
+		}
+
+	case model.OpPrefix, model.OpContains, model.OpILike:
+		s, ok := f.Value.(string)
+		if c.typ != textColumn || !ok {
+			return invalidField(f.Field, "operator %s needs a string field and value", op)
+		}
+		switch op {
+		case model.OpPrefix:
+			q.write(col + " LIKE " + q.param(escapeLike(s)+"%"))
+		case model.OpContains:
+			q.write(col + " LIKE " + q.param("%"+escapeLike(s)+"%"))
+		default:
+			q.write(col + " ILIKE " + q.param(s))
+		}
+
+	case model.OpIsNull:
+		isNull := true
+		if f.Value != nil {
+			b, ok := f.Value.(bool)
+			if !ok {
+				return invalidField(f.Field, "operator is_null needs a boolean value, got %T", f.Value)
+			}
+			isNull = b
+		}
+		if isNull {
+			q.write(col + " IS NULL")
+		} else {
+			q.write(col + " IS NOT NULL")
+		}
+
+	default:
+		return invalidField(f.Field, "unknown operator %q", op)
+	}
+	return nil
+}
+
+// comparisons - SQL-операторы для операторов сравнения фильтра.
+var comparisons = map[string]string{
+	model.OpGt:  ">",
+	model.OpGte: ">=",
+	model.OpLt:  "<",
+	model.OpLte: "<=",
+}
+
+// escapeLike экранирует в s символы, которые имеют особый смысл в шаблонах LIKE.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/aachex/service/internal/model"
+	"github.com/lib/pq"
+)
+
+type JobsRepository struct {
+	db *sql.DB
+}
+
+func NewJobsRepository(db *sql.DB) *JobsRepository {
+	return &JobsRepository{db: db}
+}
+
+// ProcessNext захватывает одно готовое к выполнению задание на обогащение и вызывает для него handle.
+// Задание остаётся заблокированным до конца обработки, поэтому параллельные обработчики его пропускают.
+//
+// Если handle завершился без ошибки, задание помечается выполненным. Иначе backoff получает номер
+// сделанной попытки и возвращает задержку до следующей; если backoff вернул false, задание помечается проваленным.
+// Возвращает false, если готовых заданий нет.
+func (r *JobsRepository) ProcessNext(
+	ctx context.Context,
+	handle func(ctx context.Context, job model.EnrichmentJob) error,
+	backoff func(attempt int) (time.Duration, bool),
+) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var job model.EnrichmentJob
+	row := tx.QueryRowContext(ctx, `
+		SELECT id, user_id, enrichers, attempts
+		FROM enrichment_jobs
+		WHERE status = 'pending' AND run_at <= now()
+		ORDER BY run_at, id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`)
+
+	err = row.Scan(&job.Id, &job.UserId, pq.Array(&job.Enrichers), &job.Attempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	handleErr := handle(ctx, job)
+	attempt := job.Attempts + 1
+
+	switch delay, retry := backoff(attempt); {
+	case handleErr == nil:
+		_, err = tx.ExecContext(ctx,
+			`UPDATE enrichment_jobs SET status = 'done', attempts = $1, last_error = NULL WHERE id = $2`,
+			attempt, job.Id)
+
+	case retry:
+		_, err = tx.ExecContext(ctx,
+			`UPDATE enrichment_jobs SET attempts = $1, last_error = $2, run_at = now() + $3 * interval '1 millisecond' WHERE id = $4`,
+			attempt, handleErr.Error(), delay.Milliseconds(), job.Id)
+
+	default:
+		_, err = tx.ExecContext(ctx,
+			`UPDATE enrichment_jobs SET status = 'failed', attempts = $1, last_error = $2 WHERE id = $3`,
+			attempt, handleErr.Error(), job.Id)
+	}
+	if err != nil {
+		return true, err
+	}
+
+	return true, tx.Commit()
+}
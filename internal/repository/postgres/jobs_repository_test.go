@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aachex/service/internal/model"
+)
+
+func TestCreateAndEnqueue(t *testing.T) {
+	loadEnv(t)
+	db := openDb(t)
+
+	users := NewUsersRepository(db)
+	jobs := NewJobsRepository(db)
+
+	id, err := users.CreateAndEnqueue(t.Context(), mock.user(), []string{"age"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// clear db
+	defer func() {
+		err = users.Purge(t.Context(), id)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	noRetry := func(int) (time.Duration, bool) { return 0, false }
+	retry := func(int) (time.Duration, bool) { return 0, true }
+
+	// первая попытка завершается ошибкой, задание остаётся в очереди
+	var handled []model.EnrichmentJob
+	fail := func(_ context.Context, job model.EnrichmentJob) error {
+		if job.UserId != id {
+			return nil
+		}
+		handled = append(handled, job)
+		return errors.New("provider is down")
+	}
+	for ok := true; ok; {
+		ok, err = jobs.ProcessNext(t.Context(), fail, retry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(handled) > 0 {
+			break
+		}
+	}
+
+	// вторая попытка успешна
+	handled = nil
+	success := func(_ context.Context, job model.EnrichmentJob) error {
+		if job.UserId == id {
+			handled = append(handled, job)
+		}
+		return nil
+	}
+	for ok := true; ok; {
+		ok, err = jobs.ProcessNext(t.Context(), success, noRetry)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(handled) != 1 {
+		t.Fatalf("wanted job to be handled once more, got %d", len(handled))
+	}
+	if handled[0].Attempts != 1 || len(handled[0].Enrichers) != 1 || handled[0].Enrichers[0] != "age" {
+		t.Errorf("unexpected job %+v", handled[0])
+	}
+}
+
+func TestEnqueueStale(t *testing.T) {
+	loadEnv(t)
+	db := openDb(t)
+
+	users := NewUsersRepository(db)
+	jobs := NewJobsRepository(db)
+
+	// пользователь без национальности, обогащённый два часа назад
+	u := mock.user()
+	u.Nationality = ""
+	enrichedAt := time.Now().Add(-2 * time.Hour)
+	u.EnrichedAt = &enrichedAt
+
+	id, err := users.Create(t.Context(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// clear db
+	defer func() {
+		err = users.Purge(t.Context(), id)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	enqueued := func() bool {
+		found := false
+		handle := func(_ context.Context, job model.EnrichmentJob) error {
+			found = found || job.UserId == id
+			return nil
+		}
+		for ok := true; ok; {
+			ok, err = jobs.ProcessNext(t.Context(), handle, func(int) (time.Duration, bool) { return 0, false })
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return found
+	}
+
+	// повторять обогащение незаполненных полей ещё рано
+	if _, err = users.EnqueueStale(t.Context(), 24*time.Hour, 3*time.Hour, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if enqueued() {
+		t.Error("user was enqueued before retryAfter")
+	}
+
+	if _, err = users.EnqueueStale(t.Context(), 24*time.Hour, time.Hour, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if !enqueued() {
+		t.Error("user with missing nationality wasn't enqueued")
+	}
+}
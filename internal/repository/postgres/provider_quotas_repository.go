@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/aachex/service/internal/enricher"
+)
+
+// ProviderQuotasRepository хранит суточные квоты внешних сервисов обогащения.
+type ProviderQuotasRepository struct {
+	db *sql.DB
+}
+
+func NewProviderQuotasRepository(db *sql.DB) *ProviderQuotasRepository {
+	return &ProviderQuotasRepository{db: db}
+}
+
+// LoadQuota возвращает сохранённую квоту провайдера.
+func (r *ProviderQuotasRepository) LoadQuota(ctx context.Context, provider string) (quota enricher.Quota, ok bool, err error) {
+	row := r.db.QueryRowContext(ctx,
+		"SELECT quota_limit, remaining, reset_at FROM provider_quotas WHERE provider = $1", provider)
+
+	err = row.Scan(&quota.Limit, &quota.Remaining, &quota.ResetAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return quota, false, nil
+	}
+	if err != nil {
+		return quota, false, err
+	}
+
+	return quota, true, nil
+}
+
+// SaveQuota сохраняет квоту провайдера, заменяя прежнюю. Квоту тех же суток тратят и другие экземпляры сервиса,
+// поэтому для неё сохраняется меньший из остатков.
+func (r *ProviderQuotasRepository) SaveQuota(ctx context.Context, provider string, quota enricher.Quota) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO provider_quotas(provider, quota_limit, remaining, reset_at) VALUES($1, $2, $3, $4)
+		ON CONFLICT (provider) DO UPDATE
+		SET quota_limit = excluded.quota_limit,
+			remaining = CASE
+				WHEN provider_quotas.reset_at = excluded.reset_at THEN LEAST(provider_quotas.remaining, excluded.remaining)
+				ELSE excluded.remaining
+			END,
+			reset_at = excluded.reset_at,
+			updated_at = now()`,
+		provider, quota.Limit, quota.Remaining, quota.ResetAt)
+	return err
+}
@@ -0,0 +1,179 @@
+package postgres
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aachex/service/internal/model"
+	"github.com/lib/pq"
+)
+
+// columnType - тип значений столбца.
+type columnType int
+
+const (
+	textColumn columnType = iota
+	intColumn
+	floatColumn
+	timeColumn
+)
+
+func (t columnType) String() string {
+	switch t {
+	case intColumn:
+		return "integer"
+	case floatColumn:
+		return "number"
+	case timeColumn:
+		return "time"
+	default:
+		return "string"
+	}
+}
+
+// column описывает, как клиент может использовать столбец таблицы.
+type column struct {
+	typ        columnType
+	nullable   bool
+	filterable bool // по столбцу можно фильтровать
+	updatable  bool // значение можно изменить методом Update
+	enrichable bool // значение определяют обогатители, и значение, заданное вручную, блокируется от них
+	confidence bool // уверенность внешнего сервиса; в фильтре прежнего формата задаётся минимум ключом min_<столбец>
+}
+
+// table - схема таблицы для построения запросов из полей, присланных клиентом.
+type table map[string]column
+
+// usersTable - схема таблицы users. Столбцы, которых здесь нет, в запросы из полей клиента не попадают.
+var usersTable = table{
+	"id":                      {typ: intColumn, filterable: true},
+	"name":                    {typ: textColumn, filterable: true, updatable: true},
+	"surname":                 {typ: textColumn, filterable: true, updatable: true},
+	"patronymic":              {typ: textColumn, nullable: true, filterable: true, updatable: true},
+	"age":                     {typ: intColumn, nullable: true, filterable: true, updatable: true, enrichable: true},
+	"gender":                  {typ: textColumn, nullable: true, filterable: true, updatable: true, enrichable: true},
+	"nationality":             {typ: textColumn, nullable: true, filterable: true, updatable: true, enrichable: true},
+	"age_count":               {typ: intColumn, filterable: true, updatable: true, confidence: true},
+	"gender_probability":      {typ: floatColumn, filterable: true, updatable: true, confidence: true},
+	"gender_count":            {typ: intColumn, filterable: true, updatable: true, confidence: true},
+	"nationality_probability": {typ: floatColumn, filterable: true, updatable: true, confidence: true},
+	"nationality_count":       {typ: intColumn, filterable: true, updatable: true, confidence: true},
+	"enriched_at":             {typ: timeColumn, nullable: true, filterable: true, updatable: true},
+	"deleted_at":              {typ: timeColumn, nullable: true, filterable: true},
+}
+
+// invalidField возвращает ошибку клиента про поле name.
+func invalidField(name, format string, args ...any) error {
+	return fmt.Errorf("%w %q: %s", model.ErrInvalidField, name, fmt.Sprintf(format, args...))
+}
+
+// filterable возвращает столбец name, если по нему можно фильтровать.
+func (t table) filterable(name string) (column, error) {
+	c, ok := t[name]
+	if !ok {
+		return c, invalidField(name, "unknown field")
+	}
+	if !c.filterable {
+		return c, invalidField(name, "field is not filterable")
+	}
+	return c, nil
+}
+
+// updatable возвращает столбец name, если его можно изменить.
+func (t table) updatable(name string) (column, error) {
+	c, ok := t[name]
+	if !ok {
+		return c, invalidField(name, "unknown field")
+	}
+	if !c.updatable {
+		return c, invalidField(name, "field is not updatable")
+	}
+	return c, nil
+}
+
+// value проверяет, что v подходит для столбца name, и приводит его к типу, который передаётся драйверу.
+// Числа из JSON приходят как float64, время - как строка в формате RFC 3339.
+func (c column) value(name string, v any) (any, error) {
+	if v == nil {
+		if !c.nullable {
+			return nil, invalidField(name, "null is not allowed")
+		}
+		return nil, nil
+	}
+
+	switch c.typ {
+	case textColumn:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case intColumn:
+		switch n := v.(type) {
+		case int:
+			return int64(n), nil
+		case int64:
+			return n, nil
+		case float64:
+			if n == math.Trunc(n) && math.Abs(n) < math.MaxInt64 {
+				return int64(n), nil
+			}
+		}
+	case floatColumn:
+		switch n := v.(type) {
+		case float64:
+			return n, nil
+		case int:
+			return float64(n), nil
+		}
+	case timeColumn:
+		switch t := v.(type) {
+		case time.Time:
+			return t, nil
+		case string:
+			parsed, err := time.Parse(time.RFC3339, t)
+			if err == nil {
+				return parsed, nil
+			}
+		}
+	}
+	return nil, invalidField(name, "expected %s, got %T", c.typ, v)
+}
+
+// stored возвращает значение v, уже проверенное методом value, в том виде, в котором оно хранится в столбце:
+// пустая строка и ноль в необязательных столбцах означают, что значение неизвестно, и хранятся как NULL.
+func (c column) stored(v any) any {
+	if c.nullable && (v == "" || v == int64(0)) {
+		return nil
+	}
+	return v
+}
+
+// queryBuilder собирает текст SQL-запроса и его параметры. Значения всегда передаются параметрами,
+// а имена столбцов - только из схемы и в кавычках.
+type queryBuilder struct {
+	sql    strings.Builder
+	params []any
+}
+
+// write добавляет к запросу фрагмент s.
+func (b *queryBuilder) write(s string) {
+	b.sql.WriteString(s)
+}
+
+// param добавляет параметр v и возвращает его плейсхолдер.
+func (b *queryBuilder) param(v any) string {
+	b.params = append(b.params, v)
+	return "$" + strconv.Itoa(len(b.params))
+}
+
+// ident возвращает имя столбца в кавычках.
+func ident(name string) string {
+	return pq.QuoteIdentifier(name)
+}
+
+// String возвращает текст запроса.
+func (b *queryBuilder) String() string {
+	return b.sql.String()
+}
@@ -0,0 +1,274 @@
+package postgres
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aachex/service/internal/model"
+)
+
+// legacyFilter переводит фильтр в прежнем формате в model.Filter.
+func legacyFilter(t *testing.T, m map[string][]any) model.Filter {
+	t.Helper()
+
+	f, err := model.FilterFromMap(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// filteringQuery строит запрос, как GetFiltered.
+func filteringQuery(filter model.Filter, page model.Page) (string, []any, error) {
+	keys, err := sortKeys(page.Sort)
+	if err != nil {
+		return "", nil, err
+	}
+	return createFilteringQuery(filter, page, keys)
+}
+
+func TestCreateFilteringQuery(t *testing.T) {
+	query, params, err := filteringQuery(legacyFilter(t, map[string][]any{
+		"name":                   {"Artem", "Dima"},
+		"age":                    {float64(23)},
+		"patronymic":             {nil},
+		"min_gender_probability": {0.9},
+	}), model.Page{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cond := range []string{
+		`"age" = $1`,
+		`"gender_probability" >= $2`,
+		`"name" IN ($3, $4)`,
+		`"patronymic" IS NULL`,
+	} {
+		if !strings.Contains(query, cond) {
+			t.Errorf("query doesn't contain %s:\n%s", cond, query)
+		}
+	}
+
+	want := []any{int64(23), 0.9, "Artem", "Dima", 11, 0}
+	if !slices.Equal(params, want) {
+		t.Errorf("wanted params %v, got %v", want, params)
+	}
+}
+
+func TestFilterOperators(t *testing.T) {
+	tests := []struct {
+		filter string
+		cond   string
+		params []any
+	}{
+		{`{"field": "age", "op": "gte", "value": 25}`, `"age" >= $1`, []any{int64(25)}},
+		{`{"field": "nationality", "op": "ne", "value": "RU"}`, `"nationality" IS DISTINCT FROM $1`, []any{"RU"}},
+		{`{"field": "nationality", "op": "not_in", "value": ["RU", "BY"]}`,
+			`("nationality" IS NULL OR NOT "nationality" IN ($1, $2))`, []any{"RU", "BY"}},
+		{`{"field": "surname", "op": "prefix", "value": "Ив_"}`, `"surname" LIKE $1`, []any{`Ив\_%`}},
+		{`{"field": "surname", "op": "contains", "value": "100%"}`, `"surname" LIKE $1`, []any{`%100\%%`}},
+		{`{"field": "name", "op": "ilike", "value": "a%"}`, `"name" ILIKE $1`, []any{"a%"}},
+		{`{"field": "patronymic", "op": "is_null"}`, `"patronymic" IS NULL`, nil},
+		{`{"field": "patronymic", "op": "is_null", "value": false}`, `"patronymic" IS NOT NULL`, nil},
+		{`{"field": "age", "op": "in", "value": []}`, `WHERE u.deleted_at IS NULL AND false`, nil},
+		{
+			`{"and": [{"field": "age", "op": "gte", "value": 25}, {"field": "age", "op": "lte", "value": 40}]}`,
+			`("age" >= $1 AND "age" <= $2)`, []any{int64(25), int64(40)},
+		},
+		{
+			`{"or": [{"field": "gender", "value": "female"}, {"not": {"field": "nationality", "value": "RU"}}]}`,
+			`("gender" = $1 OR NOT ("nationality" = $2))`, []any{"female", "RU"},
+		},
+		{
+			`{"candidate": {"countries": ["BG"], "min_probability": 0.2}}`,
+			`n.country_id IN ($1) AND n.probability >= $2`, []any{"BG", 0.2},
+		},
+		{`{}`, `WHERE u.deleted_at IS NULL AND true`, nil},
+	}
+	for _, tt := range tests {
+		var f model.Filter
+		if err := json.Unmarshal([]byte(tt.filter), &f); err != nil {
+			t.Fatal(err)
+		}
+
+		query, params, err := filteringQuery(f, model.Page{Limit: 10})
+		if err != nil {
+			t.Errorf("%s: %v", tt.filter, err)
+			continue
+		}
+		if !strings.Contains(query, tt.cond) {
+			t.Errorf("%s: query doesn't contain %s:\n%s", tt.filter, tt.cond, query)
+		}
+		if !slices.Equal(params[:len(params)-2], tt.params) {
+			t.Errorf("%s: wanted params %v, got %v", tt.filter, tt.params, params[:len(params)-2])
+		}
+	}
+}
+
+func TestFilteringQueryPagination(t *testing.T) {
+	f := model.Filter{Field: "gender", Value: "female"}
+
+	// фильтр применяется ко всей таблице, а не к уже выбранной странице
+	query, params, err := filteringQuery(f, model.Page{Offset: 20, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(query, `WHERE u.deleted_at IS NULL AND "gender" = $1 ORDER BY "id" ASC NULLS LAST LIMIT $2 OFFSET $3`) {
+		t.Errorf("page isn't taken from filtered users:\n%s", query)
+	}
+	if want := []any{"female", 11, 20}; !slices.Equal(params, want) {
+		t.Errorf("wanted params %v, got %v", want, params)
+	}
+
+	after := &model.Keyset{Values: []any{float64(42)}, Sort: "id:asc"}
+	query, params, err = filteringQuery(f, model.Page{Limit: 10, After: after})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(query, `WHERE u.deleted_at IS NULL AND "gender" = $1 AND (("id" > $2)) ORDER BY "id" ASC`) {
+		t.Errorf("page doesn't start after the keyset:\n%s", query)
+	}
+	if want := []any{"female", int64(42), 11, 0}; !slices.Equal(params, want) {
+		t.Errorf("wanted params %v, got %v", want, params)
+	}
+
+	// удалённые пользователи выбираются только по запросу
+	query, _, err = filteringQuery(f, model.Page{Limit: 10, IncludeDeleted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(query, "u.deleted_at IS NULL") {
+		t.Errorf("deleted users are excluded despite IncludeDeleted:\n%s", query)
+	}
+}
+
+func TestSortQuery(t *testing.T) {
+	sort, err := model.ParseSort("nationality, enriched_at:desc:nulls_last")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page := model.Page{Limit: 10, Sort: sort}
+	query, _, err := filteringQuery(model.Filter{}, page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `ORDER BY "nationality" ASC NULLS LAST, "enriched_at" DESC NULLS LAST, "id" ASC NULLS LAST`; !strings.Contains(query, want) {
+		t.Errorf("query doesn't contain %s:\n%s", want, query)
+	}
+
+	// позиция из курсора следующей страницы: национальность RU, время обогащения не заполнено
+	keys, _ := sortKeys(sort)
+	page.After = keysetOf([]any{"RU", nil, int64(7)}, keys)
+	query, params, err := filteringQuery(model.Filter{}, page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `WHERE u.deleted_at IS NULL AND true AND ((("nationality" > $1 OR "nationality" IS NULL))` +
+		` OR ("nationality" = $2 AND false)` +
+		` OR ("nationality" = $3 AND "enriched_at" IS NULL AND "id" > $4))`
+	if !strings.Contains(query, want) {
+		t.Errorf("query doesn't contain %s:\n%s", want, query)
+	}
+	if want := []any{"RU", "RU", "RU", int64(7), 11, 0}; !slices.Equal(params, want) {
+		t.Errorf("wanted params %v, got %v", want, params)
+	}
+
+	// курсор, выданный для другой сортировки, не принимается
+	page.Sort = sort[:1]
+	if _, _, err = filteringQuery(model.Filter{}, page); !errors.Is(err, model.ErrInvalidField) {
+		t.Errorf("wanted ErrInvalidField for another sort, got %v", err)
+	}
+
+	for _, s := range []string{"password", "age:up", "name:asc:nulls_middle"} {
+		sort, err := model.ParseSort(s)
+		if err == nil {
+			_, _, err = filteringQuery(model.Filter{}, model.Page{Limit: 10, Sort: sort})
+		}
+		if !errors.Is(err, model.ErrInvalidField) {
+			t.Errorf("sort %q: wanted ErrInvalidField, got %v", s, err)
+		}
+	}
+}
+
+func TestFilterRejectsFields(t *testing.T) {
+	tests := []string{
+		`{"name = '' OR true; --": ["x"]}`,
+		`{"password": ["x"]}`,
+		`{"min_name": ["x"]}`,
+		`{"min_age": [18]}`,
+		`{"age": ["twenty"]}`,
+		`{"age": [23.5]}`,
+		`{"name": [null]}`,
+		`{"enriched_at": ["yesterday"]}`,
+		`{"nationality_candidate": [42]}`,
+		`{"field": "age", "op": "between", "value": [1, 2]}`,
+		`{"field": "age", "op": "prefix", "value": "1"}`,
+		`{"field": "age", "op": "in", "value": 1}`,
+		`{"field": "age", "op": "gt", "value": null}`,
+		`{"field": "patronymic", "op": "is_null", "value": "yes"}`,
+		`{"field": "age", "value": 1, "and": []}`,
+		`{"or": [{"field": "age", "value": 1}, {"field": "salary", "value": 1}]}`,
+		`{"candidate": {"countries": []}}`,
+	}
+	for _, filter := range tests {
+		var f model.Filter
+		err := json.Unmarshal([]byte(filter), &f)
+		if err == nil {
+			_, _, err = filteringQuery(f, model.Page{Limit: 10})
+		}
+		if !errors.Is(err, model.ErrInvalidField) {
+			t.Errorf("%s: wanted ErrInvalidField, got %v", filter, err)
+		}
+	}
+}
+
+func TestUpdateValidation(t *testing.T) {
+	repo := NewUsersRepository(nil) // до базы дело не доходит
+
+	tests := []map[string]any{
+		{},
+		{"id": float64(1)},
+		{"provenance": "x"},
+		{"age = 1, name": "x"},
+		{"age": "17"},
+		{"age": float64(1 << 63)},
+		{"surname": nil},
+	}
+	for _, updates := range tests {
+		if err := repo.Update(t.Context(), 1, updates); !errors.Is(err, model.ErrInvalidField) {
+			t.Errorf("updates %v: wanted ErrInvalidField, got %v", updates, err)
+		}
+	}
+}
+
+func TestCountValidation(t *testing.T) {
+	repo := NewUsersRepository(nil) // до базы дело не доходит
+
+	filter := model.Filter{Field: "salary", Value: 1}
+	if _, err := repo.CountFiltered(t.Context(), filter, false); !errors.Is(err, model.ErrInvalidField) {
+		t.Errorf("CountFiltered: wanted ErrInvalidField, got %v", err)
+	}
+	if _, err := repo.GetFacets(t.Context(), filter, false); !errors.Is(err, model.ErrInvalidField) {
+		t.Errorf("GetFacets: wanted ErrInvalidField, got %v", err)
+	}
+	if _, err := repo.Suggest(t.Context(), "gender", "m", 10); !errors.Is(err, model.ErrInvalidField) {
+		t.Errorf("Suggest: wanted ErrInvalidField, got %v", err)
+	}
+}
+
+func TestManualProvenance(t *testing.T) {
+	provenance := manualProvenance(map[string]any{
+		"age":         float64(30),
+		"name":        "Anna",
+		"enriched_at": "2025-05-01T12:00:00Z",
+		"age_count":   float64(10),
+	}, model.SourceOperator)
+
+	if len(provenance) != 1 || !provenance["age"].Locked || provenance["age"].Source != model.SourceOperator {
+		t.Errorf("wanted only age to be locked, got %v", provenance)
+	}
+}
@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// cyrillicToLatin - транслитерация строчных букв кириллицы. Должна совпадать с функцией users_translit
+// из миграции 12_create_users_search.sql, по которой заполняется столбец users.search_name.
+var cyrillicToLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya",
+
+	// латинские написания, которые часто встречаются вместо принятых здесь: Jurij, Iwanow
+	'w': "v", 'j': "y",
+}
+
+// translit переводит s в латиницу в нижнем регистре, чтобы «Иванова», «Ivanova» и «Iwanowa» сравнивались одинаково.
+func translit(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if l, ok := cyrillicToLatin[r]; ok {
+			b.WriteString(l)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// searchTerms возвращает слова поискового запроса q в латинице. В словах остаются только латинские буквы
+// и цифры, поэтому их можно подставлять в to_tsquery.
+func searchTerms(q string) []string {
+	return strings.FieldsFunc(translit(q), func(r rune) bool {
+		return !('a' <= r && r <= 'z' || '0' <= r && r <= '9')
+	})
+}
+
+// prefixQuery возвращает запрос to_tsquery, который находит слова, начинающиеся со всех слов terms.
+func prefixQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		parts[i] = t + ":*"
+	}
+	return strings.Join(parts, " & ")
+}
+
+// highlightSimilarity - минимальное сходство слова с одним из слов запроса, при котором слово выделяется.
+const highlightSimilarity = 0.4
+
+// highlight возвращает s, экранированную для HTML, в которой слова, похожие на одно из слов terms, обёрнуты в <mark>.
+// Второе значение сообщает, нашлось ли такое слово.
+func highlight(s string, terms []string) (string, bool) {
+	var b strings.Builder
+	found := false
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) == isWordRune(runes[i]) {
+			j++
+		}
+		part := string(runes[i:j])
+		if isWordRune(runes[i]) && matchesTerm(translit(part), terms) {
+			b.WriteString("<mark>" + html.EscapeString(part) + "</mark>")
+			found = true
+		} else {
+			b.WriteString(html.EscapeString(part))
+		}
+		i = j
+	}
+	return b.String(), found
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// matchesTerm сообщает, начинается ли слово word с одного из слов terms или похоже на него.
+func matchesTerm(word string, terms []string) bool {
+	for _, t := range terms {
+		if strings.HasPrefix(word, t) || similarity(word, t) >= highlightSimilarity {
+			return true
+		}
+	}
+	return false
+}
+
+// similarity возвращает сходство слов a и b по триграммам так же, как функция similarity из pg_trgm:
+// долю общих триграмм среди всех триграмм обоих слов.
+func similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	common := 0
+	for t := range ta {
+		if tb[t] {
+			common++
+		}
+	}
+	total := len(ta) + len(tb) - common
+	if total == 0 {
+		return 0
+	}
+	return float64(common) / float64(total)
+}
+
+// trigrams возвращает триграммы слова word, дополненного как в pg_trgm двумя пробелами в начале и одним в конце.
+func trigrams(word string) map[string]bool {
+	runes := []rune("  " + word + " ")
+	set := make(map[string]bool, len(runes))
+	for i := 0; i+3 <= len(runes); i++ {
+		set[string(runes[i:i+3])] = true
+	}
+	return set
+}
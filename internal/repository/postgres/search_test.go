@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTranslit(t *testing.T) {
+	tests := map[string]string{
+		"Иванова":       "ivanova",
+		"Ivanova":       "ivanova",
+		"Щукин Юрий":    "shchukin yuriy",
+		"Jurij Iwanow":  "yuriy ivanov",
+		"Хачатурян-Цой": "khachaturyan-tsoy",
+		"Подъячев":      "podyachev",
+		"Артём":         "artem",
+	}
+	for s, want := range tests {
+		if got := translit(s); got != want {
+			t.Errorf("translit(%q): wanted %q, got %q", s, want, got)
+		}
+	}
+}
+
+func TestSearchTerms(t *testing.T) {
+	terms := searchTerms(" Иванова  Мар' & !")
+	if want := []string{"ivanova", "mar"}; !slices.Equal(terms, want) {
+		t.Errorf("wanted terms %v, got %v", want, terms)
+	}
+	if q := prefixQuery(terms); q != "ivanova:* & mar:*" {
+		t.Errorf("wanted prefix query %q, got %q", "ivanova:* & mar:*", q)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		s     string
+		terms []string
+		want  string
+		found bool
+	}{
+		{"Мария", []string{"ivanova", "mar"}, "<mark>Мария</mark>", true},
+		{"Анна-Мария", []string{"mar"}, "Анна-<mark>Мария</mark>", true},
+		{"Иваново", []string{"ivanova"}, "<mark>Иваново</mark>", true},
+		{"Петрова", []string{"ivanova"}, "Петрова", false},
+		{"<b>Ivanova</b>", []string{"ivanova"}, "&lt;b&gt;<mark>Ivanova</mark>&lt;/b&gt;", true},
+	}
+	for _, tt := range tests {
+		got, found := highlight(tt.s, tt.terms)
+		if got != tt.want || found != tt.found {
+			t.Errorf("highlight(%q, %v): wanted %q, %v, got %q, %v", tt.s, tt.terms, tt.want, tt.found, got, found)
+		}
+	}
+}
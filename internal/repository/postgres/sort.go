@@ -0,0 +1,137 @@
+package postgres
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/aachex/service/internal/model"
+)
+
+// sortKey - ключ сортировки, проверенный по схеме таблицы.
+type sortKey struct {
+	model.SortKey
+	col column
+}
+
+// sortKeys проверяет ключи сортировки keys и дополняет их id, чтобы порядок был однозначным
+// и по нему можно было продолжить выборку с позиции. Сортировать можно по полям, по которым можно фильтровать.
+func sortKeys(keys []model.SortKey) ([]sortKey, error) {
+	checked := make([]sortKey, 0, len(keys)+1)
+	for _, k := range keys {
+		c, err := usersTable.filterable(k.Field)
+		if err != nil {
+			return nil, err
+		}
+		checked = append(checked, sortKey{k, c})
+
+		// id уникален, поэтому следующие ключи уже ничего не меняют
+		if k.Field == "id" {
+			return checked, nil
+		}
+	}
+	return append(checked, sortKey{model.SortKey{Field: "id"}, usersTable["id"]}), nil
+}
+
+// formatSortKeys возвращает проверенные ключи сортировки в формате model.ParseSort.
+func formatSortKeys(keys []sortKey) string {
+	plain := make([]model.SortKey, len(keys))
+	for i, k := range keys {
+		plain[i] = k.SortKey
+	}
+	return model.FormatSort(plain)
+}
+
+// writeOrderBy добавляет к запросу сортировку по ключам keys.
+func writeOrderBy(q *queryBuilder, keys []sortKey) {
+	q.write(" ORDER BY ")
+	for i, k := range keys {
+		if i > 0 {
+			q.write(", ")
+		}
+		q.write(ident(k.Field))
+		if k.Desc {
+			q.write(" DESC")
+		} else {
+			q.write(" ASC")
+		}
+		if k.NullsFirst() {
+			q.write(" NULLS FIRST")
+		} else {
+			q.write(" NULLS LAST")
+		}
+	}
+}
+
+// writeAfter добавляет к запросу условие, что пользователь идёт после позиции after в порядке keys.
+func writeAfter(q *queryBuilder, keys []sortKey, after model.Keyset) error {
+	if after.Sort != formatSortKeys(keys) || len(after.Values) != len(keys) {
+		return fmt.Errorf("%w: cursor was issued for another sort", model.ErrInvalidField)
+	}
+
+	// значения из курсора прочитаны из JSON и приводятся к типам столбцов
+	values := make([]any, len(keys))
+	for i, k := range keys {
+		v, err := k.col.value(k.Field, after.Values[i])
+		if err != nil {
+			return err
+		}
+		values[i] = v
+	}
+
+	// пользователь идёт после позиции, если первые i ключей у них совпадают, а i-й ключ идёт позже
+	q.write(" AND (")
+	for i, k := range keys {
+		if i > 0 {
+			q.write(" OR ")
+		}
+		q.write("(")
+		for j := range i {
+			writeEqual(q, keys[j], values[j])
+			q.write(" AND ")
+		}
+		writeGreater(q, k, values[i])
+		q.write(")")
+	}
+	q.write(")")
+	return nil
+}
+
+// writeEqual добавляет к запросу условие, что ключ k равен v, в том числе когда оба пусты.
+func writeEqual(q *queryBuilder, k sortKey, v any) {
+	if v == nil {
+		q.write(ident(k.Field) + " IS NULL")
+		return
+	}
+	q.write(ident(k.Field) + " = " + q.param(v))
+}
+
+// writeGreater добавляет к запросу условие, что ключ k идёт в порядке сортировки строго после v.
+func writeGreater(q *queryBuilder, k sortKey, v any) {
+	col := ident(k.Field)
+
+	// после пустых значений идут непустые, если пустые в начале, и ничего, если в конце
+	if v == nil {
+		if k.NullsFirst() {
+			q.write(col + " IS NOT NULL")
+		} else {
+			q.write("false")
+		}
+		return
+	}
+
+	op := " > "
+	if k.Desc {
+		op = " < "
+	}
+	if !k.col.nullable || k.NullsFirst() {
+		q.write(col + op + q.param(v))
+		return
+	}
+	q.write("(" + col + op + q.param(v) + " OR " + col + " IS NULL)")
+}
+
+// keysetOf возвращает позицию в порядке keys по значениям ключей values, прочитанным из базы.
+// Пустые значения остаются nil, чтобы следующая страница начиналась после них, а не повторяла их.
+func keysetOf(values []any, keys []sortKey) *model.Keyset {
+	return &model.Keyset{Values: slices.Clone(values), Sort: formatSortKeys(keys)}
+}
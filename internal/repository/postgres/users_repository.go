@@ -5,9 +5,13 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/aachex/service/internal/model"
+	"github.com/lib/pq"
 )
 
 type UsersRepository struct {
@@ -18,87 +22,483 @@ func NewUsersRepository(db *sql.DB) *UsersRepository {
 	return &UsersRepository{db: db}
 }
 
+// userColumns - столбцы таблицы users в порядке, в котором их читает scanUser.
+const userColumns = `id, name, surname, patronymic, age, gender, nationality,
+	age_count, gender_probability, gender_count, nationality_probability, nationality_count, enriched_at, deleted_at`
+
+// scanner - общий интерфейс *sql.Row и *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanUser читает пользователя из строки, выбранной по столбцам userColumns, за которыми идут столбцы extra.
+// Пустые значения отчества, возраста, пола и национальности читаются как нулевые.
+func scanUser(row scanner, extra ...any) (u model.User, err error) {
+	var (
+		patronymic, gender, nationality sql.NullString
+		age                             sql.NullInt64
+	)
+	dest := []any{&u.Id, &u.Name, &u.Surname, &patronymic, &age, &gender, &nationality,
+		&u.AgeCount, &u.GenderProbability, &u.GenderCount, &u.NationalityProbability, &u.NationalityCount, &u.EnrichedAt, &u.DeletedAt}
+	if err = row.Scan(append(dest, extra...)...); err != nil {
+		return u, err
+	}
+
+	u.Patronymic = patronymic.String
+	u.Age = int(age.Int64)
+	u.Gender = gender.String
+	u.Nationality = nationality.String
+	return u, nil
+}
+
 // createFilteringQuery генерирует SQL-запрос, который фильтрует и возвращает данные в соответствии с фильтром filter.
-func createFilteringQuery(offset, limit int, filter map[string][]any) (query string, params []any) {
-	// запрос по умолчанию, который вернёт выборку пользователей
-	query = `
-		SELECT *
-		FROM (SELECT * FROM users OFFSET $1 LIMIT $2) 
-		WHERE true
-	`
+// Поля фильтра проверяются по схеме таблицы users, а неизвестные поля, операторы и значения
+// неподходящих типов приводят к ошибке model.ErrInvalidField.
+//
+// Удалённые пользователи не выбираются, если не задан page.IncludeDeleted.
+// Страница page выбирается из уже отфильтрованных и упорядоченных по page.Sort пользователей. Запрос возвращает
+// на одного пользователя больше, чем page.Limit, чтобы было видно, есть ли следующая страница.
+// После столбцов userColumns выбираются ключи сортировки keys для позиции следующей страницы.
+func createFilteringQuery(filter model.Filter, page model.Page, keys []sortKey) (query string, params []any, err error) {
+	var q queryBuilder
 
-	params = []any{offset, limit}
+	q.write(`
+		SELECT ` + userColumns)
+	for _, k := range keys {
+		q.write(", " + ident(k.Field))
+	}
+	q.write(`
+		FROM users u`)
 
-	// Начинаем с третьего параметра, потому что параметры 1 и 2 - offset и limit
-	pholder := 3
-	for field, targets := range filter {
-		if field == "" || len(targets) == 0 {
-			continue
-		}
+	if err = writeWhere(&q, filter, page.IncludeDeleted); err != nil {
+		return "", nil, err
+	}
 
-		// field - имя поля в базе данных
-		// targets - желаемое значение для k
-		// pholder - номер плейсхолдера ($1, $2 и т. д.)
-		query += " AND ("
-		for _, t := range targets {
-			query += fmt.Sprintf(" %s = $%d OR", field, pholder)
-			params = append(params, t)
-			pholder++
+	// следующая страница начинается сразу после последнего пользователя предыдущей
+	if page.After != nil {
+		if err = writeAfter(&q, keys, *page.After); err != nil {
+			return "", nil, err
 		}
-
-		query = strings.TrimSuffix(query, " OR") // убираем последний OR
-		query += ")"
 	}
 
-	query += " ORDER BY id"
+	writeOrderBy(&q, keys)
+	q.write(" LIMIT " + q.param(page.Limit+1) + " OFFSET " + q.param(page.Offset))
 
-	return query, params
+	return q.String(), q.params, nil
 }
 
-// GetFiltered возвращает список пользователей, поля которых равны указанным значениям.
-// Параметр filter является мапой, в которой ключи - имена свойств, а значения - желаемые значения для свойств.
-func (r *UsersRepository) GetFiltered(ctx context.Context, filter map[string][]any, offset, limit int) ([]model.User, error) {
-	query, params := createFilteringQuery(offset, limit, filter)
+// GetFiltered возвращает страницу page пользователей, которые подходят под фильтр filter, и позицию,
+// с которой начинается следующая страница, или nil, если это последняя страница.
+// Пользователи упорядочиваются по ключам page.Sort, а при равенстве ключей - по id.
+// Если в фильтре неизвестное поле или оператор либо значение не подходит по типу, возвращается ошибка model.ErrInvalidField.
+func (r *UsersRepository) GetFiltered(ctx context.Context, filter model.Filter, page model.Page) ([]model.User, *model.Keyset, error) {
+	keys, err := sortKeys(page.Sort)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query, params, err := createFilteringQuery(filter, page, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	rows, err := r.db.QueryContext(ctx, query, params...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	// ключи читаются как есть, чтобы пустые значения в позиции остались пустыми
+	values := make([]any, len(keys))
+	dest := make([]any, len(keys))
+	for i := range values {
+		dest[i] = &values[i]
 	}
 
+	var next *model.Keyset
 	users := make([]model.User, 0)
-	var u model.User
 	for rows.Next() {
-		err = rows.Scan(&u.Id, &u.Name, &u.Surname, &u.Patronymic, &u.Age, &u.Gender, &u.Nationality)
+		u, err := scanUser(rows, dest...)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		users = append(users, u)
+
+		// позиция последнего пользователя страницы
+		if len(users) == page.Limit {
+			next = keysetOf(values, keys)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(users) <= page.Limit {
+		return users, nil, nil
+	}
+	return users[:page.Limit], next, nil
+}
+
+// writeWhere добавляет к запросу по таблице users u условие WHERE по фильтру filter.
+// Удалённые пользователи отбрасываются, если не задан includeDeleted.
+func writeWhere(q *queryBuilder, filter model.Filter, includeDeleted bool) error {
+	q.write(" WHERE ")
+	if !includeDeleted {
+		q.write("u.deleted_at IS NULL AND ")
+	}
+	return writeFilter(q, filter)
+}
+
+// CountFiltered возвращает количество пользователей, которые подходят под фильтр filter.
+// Удалённые пользователи учитываются, только если задан includeDeleted.
+func (r *UsersRepository) CountFiltered(ctx context.Context, filter model.Filter, includeDeleted bool) (int, error) {
+	var q queryBuilder
+	q.write("SELECT COUNT(*) FROM users u")
+	if err := writeWhere(&q, filter, includeDeleted); err != nil {
+		return 0, err
 	}
 
-	return users, nil
+	var total int
+	err := r.db.QueryRowContext(ctx, q.String(), q.params...).Scan(&total)
+	return total, err
 }
 
-func (r *UsersRepository) GetById(ctx context.Context, id int64, offset, limit int) (user model.User, err error) {
-	filter := map[string][]any{
-		"id": {id},
+// ageBuckets - диапазоны возраста для фасетов: возраст меньше below попадает в диапазон label.
+// Возраст не меньше последней границы попадает в диапазон "65+".
+var ageBuckets = []struct {
+	below int
+	label string
+}{
+	{18, "0-17"},
+	{25, "18-24"},
+	{35, "25-34"},
+	{45, "35-44"},
+	{55, "45-54"},
+	{65, "55-64"},
+}
+
+// GetFacets возвращает количество пользователей, подходящих под фильтр filter, по полу, национальности
+// и диапазонам возраста. Пользователи с незаполненным полем учитываются под ключом model.FacetUnknown.
+// Удалённые пользователи учитываются, только если задан includeDeleted.
+func (r *UsersRepository) GetFacets(ctx context.Context, filter model.Filter, includeDeleted bool) (model.Facets, error) {
+	var q queryBuilder
+
+	unknown := q.param(model.FacetUnknown)
+	q.write(`
+		SELECT GROUPING(gender), GROUPING(nationality), gender, nationality, age, COUNT(*)
+		FROM (
+			SELECT
+				COALESCE(NULLIF(u.gender, ''), ` + unknown + `) AS gender,
+				COALESCE(NULLIF(u.nationality, ''), ` + unknown + `) AS nationality,
+				CASE WHEN u.age IS NULL OR u.age <= 0 THEN ` + unknown)
+	for _, b := range ageBuckets {
+		q.write(" WHEN u.age < " + q.param(b.below) + " THEN " + q.param(b.label))
+	}
+	q.write(" ELSE " + q.param(fmt.Sprintf("%d+", ageBuckets[len(ageBuckets)-1].below)) + ` END AS age
+			FROM users u`)
+	if err := writeWhere(&q, filter, includeDeleted); err != nil {
+		return model.Facets{}, err
 	}
-	match, err := r.GetFiltered(ctx, filter, offset, limit)
+	q.write(`
+		) f
+		GROUP BY GROUPING SETS ((gender), (nationality), (age))`)
+
+	rows, err := r.db.QueryContext(ctx, q.String(), q.params...)
 	if err != nil {
-		return user, err
+		return model.Facets{}, err
+	}
+	defer rows.Close()
+
+	facets := model.Facets{
+		Gender:      make(map[string]int),
+		Nationality: make(map[string]int),
+		Age:         make(map[string]int),
+	}
+	for rows.Next() {
+		var (
+			noGender, noNationality     int
+			gender, nationality, bucket sql.NullString
+			count                       int
+		)
+		if err = rows.Scan(&noGender, &noNationality, &gender, &nationality, &bucket, &count); err != nil {
+			return model.Facets{}, err
+		}
+
+		// GROUPING равен 0 у столбца, по которому сгруппирована строка
+		switch {
+		case noGender == 0:
+			facets.Gender[gender.String] = count
+		case noNationality == 0:
+			facets.Nationality[nationality.String] = count
+		default:
+			facets.Age[bucket.String] = count
+		}
+	}
+	return facets, rows.Err()
+}
+
+// Search ищет не больше limit пользователей, ФИО которых похоже на запрос q, по убыванию сходства.
+// Запрос и ФИО сравниваются в латинице, поэтому кириллица и латиница взаимозаменяемы, а опечатки
+// сглаживаются сравнением по триграммам. Последнее слово запроса может быть началом слова: «Иванова Мар».
+// Удалённые пользователи не ищутся.
+func (r *UsersRepository) Search(ctx context.Context, q string, limit int) ([]model.SearchResult, error) {
+	results := make([]model.SearchResult, 0)
+
+	terms := searchTerms(q)
+	if len(terms) == 0 {
+		return results, nil
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+userColumns+`, word_similarity($1, u.search_name) AS score
+		FROM users u
+		WHERE u.deleted_at IS NULL
+			AND ($1 <% u.search_name OR to_tsvector('simple', u.search_name) @@ to_tsquery('simple', $2))
+		ORDER BY score DESC, u.id
+		LIMIT $3`,
+		strings.Join(terms, " "), prefixQuery(terms), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var res model.SearchResult
+		if res.User, err = scanUser(rows, &res.Score); err != nil {
+			return nil, err
+		}
+
+		fields := map[string]string{"name": res.Name, "surname": res.Surname, "patronymic": res.Patronymic}
+		for field, value := range fields {
+			if marked, ok := highlight(value, terms); ok {
+				if res.Highlight == nil {
+					res.Highlight = make(map[string]string)
+				}
+				res.Highlight[field] = marked
+			}
+		}
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
+
+// suggestFields - поля, для которых есть подсказки. По ним построены индексы по началу значения
+// из миграции 13_create_users_prefix_indexes.sql.
+var suggestFields = []string{"name", "surname", "patronymic"}
+
+// Suggest возвращает не больше limit различных значений поля field, начинающихся с prefix без учёта регистра,
+// и число неудалённых пользователей с каждым из них. Сначала идут самые частые значения.
+// Для полей не из suggestFields возвращается ошибка model.ErrInvalidField.
+func (r *UsersRepository) Suggest(ctx context.Context, field, prefix string, limit int) ([]model.Suggestion, error) {
+	if !slices.Contains(suggestFields, field) {
+		return nil, invalidField(field, "field has no suggestions")
 	}
 
-	if len(match) == 0 {
+	col := ident(field)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+col+`, COUNT(*)
+		FROM users
+		WHERE lower(`+col+`) LIKE lower($1) || '%' AND deleted_at IS NULL
+		GROUP BY `+col+`
+		ORDER BY COUNT(*) DESC, `+col+`
+		LIMIT $2`,
+		escapeLike(prefix), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := make([]model.Suggestion, 0)
+	for rows.Next() {
+		var s model.Suggestion
+		if err = rows.Scan(&s.Value, &s.Count); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, s)
+	}
+	return suggestions, rows.Err()
+}
+
+// GetById возвращает пользователя по id. Если пользователь не найден или удалён, возвращается пустой пользователь.
+func (r *UsersRepository) GetById(ctx context.Context, id int64) (user model.User, err error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1 AND deleted_at IS NULL", id)
+	user, err = scanUser(row)
+	if errors.Is(err, sql.ErrNoRows) {
 		return user, nil
 	}
+	if err != nil {
+		return user, err
+	}
+
+	users := []model.User{user}
+	if err = r.LoadProvenance(ctx, users); err != nil {
+		return user, err
+	}
+	return users[0], nil
+}
+
+// LoadProvenance заполняет происхождение значений полей пользователей users.
+func (r *UsersRepository) LoadProvenance(ctx context.Context, users []model.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(users))
+	byId := make(map[int64]int, len(users))
+	for i, u := range users {
+		ids[i] = u.Id
+		byId[u.Id] = i
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT user_id, field, source, provider, raw, updated_at, locked FROM user_provenance WHERE user_id = ANY($1)",
+		pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id    int64
+			field string
+			p     model.Provenance
+		)
+		if err = rows.Scan(&id, &field, &p.Source, &p.Provider, &p.Raw, &p.UpdatedAt, &p.Locked); err != nil {
+			return err
+		}
+		users[byId[id]].SetProvenance(field, p)
+	}
+	return rows.Err()
+}
+
+// Create создаёт нового пользователя в базе данных вместе с происхождением значений его полей,
+// расхождениями источников обогащения и странами-кандидатами.
+// Id пользователя игнорируется.
+func (r *UsersRepository) Create(ctx context.Context, user model.User) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return -1, err
+	}
+	defer tx.Rollback()
+
+	uid, err := insertUser(ctx, tx, user)
+	if err != nil {
+		return -1, err
+	}
+
+	if err = saveProvenance(ctx, tx, uid, user.Provenance); err != nil {
+		return -1, err
+	}
+
+	if err = saveConflicts(ctx, tx, uid, user.Conflicts); err != nil {
+		return -1, err
+	}
+
+	if err = saveNationalities(ctx, tx, uid, user.Nationalities); err != nil {
+		return -1, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return -1, err
+	}
+	return uid, nil
+}
+
+// CreateAndEnqueue создаёт нового пользователя и в той же транзакции ставит задание на его обогащение
+// обогатителями enrichers. Пустой enrichers означает все обогатители.
+func (r *UsersRepository) CreateAndEnqueue(ctx context.Context, user model.User, enrichers []string) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return -1, err
+	}
+	defer tx.Rollback()
+
+	uid, err := insertUser(ctx, tx, user)
+	if err != nil {
+		return -1, err
+	}
+
+	if err = saveProvenance(ctx, tx, uid, user.Provenance); err != nil {
+		return -1, err
+	}
 
-	return match[0], nil
+	if err = saveConflicts(ctx, tx, uid, user.Conflicts); err != nil {
+		return -1, err
+	}
+
+	if err = saveNationalities(ctx, tx, uid, user.Nationalities); err != nil {
+		return -1, err
+	}
+
+	if err = enqueue(ctx, tx, uid, enrichers); err != nil {
+		return -1, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return -1, err
+	}
+	return uid, nil
+}
+
+// Enqueue ставит задание на повторное обогащение пользователя id обогатителями enrichers.
+// Пустой enrichers означает все обогатители.
+func (r *UsersRepository) Enqueue(ctx context.Context, id int64, enrichers []string) error {
+	return enqueue(ctx, r.db, id, enrichers)
+}
+
+// EnqueueStale ставит задания на обогащение не более чем limit пользователям, которые давно не обогащались:
+// ни разу, раньше чем maxAge назад или, если у них не заполнено одно из полей, раньше чем retryAfter назад.
+// Удалённые пользователи и пользователи, для которых задание уже ждёт в очереди, пропускаются. Возвращает количество поставленных заданий.
+func (r *UsersRepository) EnqueueStale(ctx context.Context, maxAge, retryAfter time.Duration, limit int) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO enrichment_jobs(user_id)
+		SELECT u.id
+		FROM users u
+		WHERE u.deleted_at IS NULL AND NOT EXISTS (
+			SELECT 1 FROM enrichment_jobs j WHERE j.user_id = u.id AND j.status = 'pending'
+		) AND (
+			u.enriched_at IS NULL
+			OR u.enriched_at < now() - $1 * interval '1 millisecond'
+			OR (
+				(COALESCE(u.age, 0) = 0 OR COALESCE(u.gender, '') = '' OR COALESCE(u.nationality, '') = '')
+				AND u.enriched_at < now() - $2 * interval '1 millisecond'
+			)
+		)
+		ORDER BY u.enriched_at NULLS FIRST, u.id
+		LIMIT $3`,
+		maxAge.Milliseconds(), retryAfter.Milliseconds(), limit)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// execer - общий интерфейс *sql.DB и *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func enqueue(ctx context.Context, e execer, uid int64, enrichers []string) error {
+	_, err := e.ExecContext(ctx,
+		"INSERT INTO enrichment_jobs(user_id, enrichers) VALUES($1, $2)", uid, pq.Array(enrichers))
+	return err
+}
+
+// queryRower - общий интерфейс *sql.DB и *sql.Tx.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 }
 
-// Create создаёт нового пользователя в базе данных.
-func (r *UsersRepository) Create(ctx context.Context, name, surname, patronymic string, age int, gender, nationality string) (int64, error) {
-	row := r.db.QueryRowContext(
+// insertUser добавляет пользователя u. Незаполненные отчество, возраст, пол и национальность сохраняются как NULL.
+func insertUser(ctx context.Context, q queryRower, u model.User) (int64, error) {
+	row := q.QueryRowContext(
 		ctx,
-		`INSERT INTO users(name, surname, patronymic, age, gender, nationality) 
-		VALUES($1, $2, $3, $4, $5, $6) RETURNING id`, name, surname, patronymic, age, gender, nationality)
+		`INSERT INTO users(name, surname, patronymic, age, gender, nationality,
+			age_count, gender_probability, gender_count, nationality_probability, nationality_count, enriched_at) 
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`,
+		u.Name, u.Surname, nullIfEmpty(u.Patronymic), nullIfEmpty(u.Age), nullIfEmpty(u.Gender), nullIfEmpty(u.Nationality),
+		u.AgeCount, u.GenderProbability, u.GenderCount, u.NationalityProbability, u.NationalityCount, u.EnrichedAt)
 
 	var uid int64
 	if err := row.Scan(&uid); err != nil {
@@ -108,47 +508,307 @@ func (r *UsersRepository) Create(ctx context.Context, name, surname, patronymic
 	return uid, nil
 }
 
+// nullIfEmpty возвращает nil вместо нулевого значения v.
+func nullIfEmpty[T comparable](v T) any {
+	var zero T
+	if v == zero {
+		return nil
+	}
+	return v
+}
+
+// Update обновляет поля пользователя id значениями, заданными вручную через API.
+// Обновлённые поля блокируются: обогатители больше не перезаписывают их, пока поле не разблокировано методом Unlock.
+// Если поле нельзя изменить или значение не подходит по типу, возвращается ошибка model.ErrInvalidField,
+// а если пользователя нет или он удалён - model.ErrNotFound.
 func (r *UsersRepository) Update(ctx context.Context, id int64, updates map[string]any) error {
-	if len(updates) == 0 {
-		return errors.New("no updates")
+	return r.update(ctx, id, model.Enrichment{
+		Values:        updates,
+		Provenance:    manualProvenance(updates, model.SourceOperator),
+		Nationalities: manualNationalities(updates),
+	}, false)
+}
+
+// Import обновляет поля пользователя id значениями, загруженными из внешней системы, и блокирует их, как Update.
+func (r *UsersRepository) Import(ctx context.Context, id int64, updates map[string]any) error {
+	return r.update(ctx, id, model.Enrichment{
+		Values:        updates,
+		Provenance:    manualProvenance(updates, model.SourceImport),
+		Nationalities: manualNationalities(updates),
+	}, false)
+}
+
+// SaveEnrichment сохраняет результаты обогащения пользователя id: значения полей, их происхождение,
+// расхождения источников и страны-кандидаты. Заблокированные поля не обновляются,
+// даже если их заблокировали уже после чтения пользователя.
+func (r *UsersRepository) SaveEnrichment(ctx context.Context, id int64, changes model.Enrichment) error {
+	return r.update(ctx, id, changes, true)
+}
+
+// Unlock разблокирует поля fields пользователя id, чтобы обогатители снова могли их перезаписывать.
+// Возвращает количество разблокированных полей.
+func (r *UsersRepository) Unlock(ctx context.Context, id int64, fields ...string) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		"UPDATE user_provenance SET locked = false WHERE user_id = $1 AND field = ANY($2) AND locked",
+		id, pq.Array(fields))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// manualProvenance возвращает заблокированное происхождение source для полей из updates, которые определяют обогатители.
+// Остальные поля, например enriched_at, не блокируются: иначе обогащение не смогло бы их обновить.
+func manualProvenance(updates map[string]any, source string) map[string]model.Provenance {
+	now := time.Now()
+	provenance := make(map[string]model.Provenance, len(updates))
+	for field := range updates {
+		if usersTable[field].enrichable {
+			provenance[field] = model.Provenance{Source: source, UpdatedAt: now, Locked: true}
+		}
+	}
+	return provenance
+}
+
+// manualNationalities возвращает страны-кандидаты для национальности, заданной вручную в updates: только её саму
+// или ни одной, если национальность очищена. Иначе фильтры по кандидатам находили бы страны, которые заменил оператор.
+// Возвращает nil, если национальность не меняется.
+func manualNationalities(updates map[string]any) []model.Nationality {
+	v, ok := updates["nationality"]
+	if !ok {
+		return nil
+	}
+	if country, ok := v.(string); ok && country != "" {
+		return []model.Nationality{{Country: country, Probability: 1}}
+	}
+	return []model.Nationality{}
+}
+
+// update сохраняет изменения changes пользователя id. Если skipLocked равен true,
+// изменения заблокированных полей пропускаются. Если пользователя нет или он удалён, возвращает model.ErrNotFound.
+func (r *UsersRepository) update(ctx context.Context, id int64, changes model.Enrichment, skipLocked bool) error {
+	if len(changes.Values) == 0 {
+		return fmt.Errorf("%w: no updates", model.ErrInvalidField)
+	}
+
+	// значения проверяются до начала транзакции, чтобы ошибка клиента не доходила до базы
+	values := make(map[string]any, len(changes.Values))
+	for field, v := range changes.Values {
+		c, err := usersTable.updatable(field)
+		if err != nil {
+			return err
+		}
+		if v, err = c.value(field, v); err != nil {
+			return err
+		}
+		values[field] = c.stored(v)
 	}
-	if _, ok := updates["id"]; ok {
-		return errors.New("field id is not updatable")
+	changes.Values = values
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	// строим SQL-запрос, который обновит все поля, указанные в updates
-	params := []any{}
-	updQuery := "UPDATE USERS SET"
-	pholder := 1
-	for field, val := range updates {
-		updQuery += fmt.Sprintf(" %s = $%d", field, pholder)
-		if pholder < len(updates) {
-			updQuery += ","
+	if skipLocked {
+		locked, err := lockedFields(ctx, tx, id)
+		if err != nil {
+			return err
 		}
+		changes = withoutLocked(changes, locked)
 
-		params = append(params, val)
-		pholder++
+		if len(changes.Values) == 0 {
+			return nil
+		}
 	}
 
-	updQuery += fmt.Sprintf(" WHERE id = $%d", pholder)
-	params = append(params, id)
+	// строим SQL-запрос, который обновит все поля, указанные в changes.Values
+	var q queryBuilder
+	q.write("UPDATE users SET ")
+	for i, field := range slices.Sorted(maps.Keys(changes.Values)) {
+		if i > 0 {
+			q.write(", ")
+		}
+		q.write(ident(field) + " = " + q.param(changes.Values[field]))
+	}
+	q.write(" WHERE id = " + q.param(id) + " AND deleted_at IS NULL")
 
-	_, err := r.db.ExecContext(ctx, updQuery, params...)
+	res, err := tx.ExecContext(ctx, q.String(), q.params...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
+	if n == 0 {
+		return model.ErrNotFound
+	}
 
+	if err = saveProvenance(ctx, tx, id, changes.Provenance); err != nil {
+		return err
+	}
+
+	if err = saveConflicts(ctx, tx, id, changes.Conflicts); err != nil {
+		return err
+	}
+
+	if changes.Nationalities != nil {
+		if err = saveNationalities(ctx, tx, id, changes.Nationalities); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// withoutLocked возвращает копию changes без изменений заблокированных полей locked.
+func withoutLocked(changes model.Enrichment, locked map[string]bool) model.Enrichment {
+	changes.Values = maps.Clone(changes.Values)
+	maps.DeleteFunc(changes.Values, func(column string, _ any) bool { return fieldLocked(locked, column) })
+
+	changes.Provenance = maps.Clone(changes.Provenance)
+	maps.DeleteFunc(changes.Provenance, func(field string, _ model.Provenance) bool { return locked[field] })
+
+	changes.Conflicts = slices.DeleteFunc(slices.Clone(changes.Conflicts), func(c model.EnrichmentConflict) bool {
+		return locked[c.Field]
+	})
+
+	if locked["nationality"] {
+		changes.Nationalities = nil
+	}
+	return changes
+}
+
+// saveNationalities заменяет страны-кандидаты пользователя uid на nationalities.
+func saveNationalities(ctx context.Context, e execer, uid int64, nationalities []model.Nationality) error {
+	_, err := e.ExecContext(ctx, "DELETE FROM user_nationalities WHERE user_id = $1", uid)
+	if err != nil {
+		return err
+	}
+
+	for rank, n := range nationalities {
+		_, err = e.ExecContext(ctx,
+			"INSERT INTO user_nationalities(user_id, country_id, probability, rank) VALUES($1, $2, $3, $4)",
+			uid, n.Country, n.Probability, rank+1)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// Delete удаляет пользователя из базы данных по id.
+// GetNationalities возвращает страны-кандидаты пользователя id по убыванию вероятности.
+func (r *UsersRepository) GetNationalities(ctx context.Context, id int64) ([]model.Nationality, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT country_id, probability FROM user_nationalities WHERE user_id = $1 ORDER BY rank", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	nationalities := make([]model.Nationality, 0)
+	for rows.Next() {
+		var n model.Nationality
+		if err = rows.Scan(&n.Country, &n.Probability); err != nil {
+			return nil, err
+		}
+		nationalities = append(nationalities, n)
+	}
+	return nationalities, rows.Err()
+}
+
+// lockedFields возвращает заблокированные поля пользователя uid и не даёт изменить их блокировку до конца транзакции.
+func lockedFields(ctx context.Context, tx *sql.Tx, uid int64) (map[string]bool, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT field FROM user_provenance WHERE user_id = $1 AND locked FOR UPDATE", uid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locked := make(map[string]bool)
+	for rows.Next() {
+		var field string
+		if err = rows.Scan(&field); err != nil {
+			return nil, err
+		}
+		locked[field] = true
+	}
+	return locked, rows.Err()
+}
+
+// fieldLocked сообщает, относится ли столбец column к заблокированному полю. Столбцы уверенности
+// вида age_count и gender_probability относятся к полю, имя которого стоит в их начале.
+func fieldLocked(locked map[string]bool, column string) bool {
+	for field := range locked {
+		if column == field || strings.HasPrefix(column, field+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+// saveProvenance сохраняет происхождение значений полей пользователя uid, заменяя прежнее.
+func saveProvenance(ctx context.Context, e execer, uid int64, provenance map[string]model.Provenance) error {
+	for field, p := range provenance {
+		_, err := e.ExecContext(ctx, `
+			INSERT INTO user_provenance(user_id, field, source, provider, raw, updated_at, locked)
+			VALUES($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (user_id, field) DO UPDATE
+			SET source = $3, provider = $4, raw = $5, updated_at = $6, locked = $7`,
+			uid, field, p.Source, p.Provider, p.Raw, p.UpdatedAt, p.Locked)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete помечает пользователя с указанным id удалённым. Удалённый пользователь не выбирается
+// и не обогащается, но остаётся в базе, пока его не очистит PurgeDeleted, и его можно восстановить методом Restore.
 func (r *UsersRepository) Delete(ctx context.Context, uid int64) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL", uid)
+	return err
+}
+
+// Restore восстанавливает удалённого пользователя. Возвращает false, если пользователя нет или он не удалён.
+func (r *UsersRepository) Restore(ctx context.Context, uid int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE users SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL", uid)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Purge удаляет пользователя из базы данных по id безвозвратно, удалён он или нет.
+func (r *UsersRepository) Purge(ctx context.Context, uid int64) error {
 	_, err := r.db.ExecContext(ctx, "DELETE FROM users WHERE id = $1", uid)
 	return err
 }
 
-// Exists воззвращает true, если пользователь с указанным id существует, иначе false.
+// PurgeDeleted безвозвратно удаляет не более limit пользователей, удалённых раньше before.
+// Возвращает количество удалённых.
+func (r *UsersRepository) PurgeDeleted(ctx context.Context, before time.Time, limit int) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM users
+		WHERE id IN (
+			SELECT id FROM users
+			WHERE deleted_at < $1
+			ORDER BY deleted_at
+			LIMIT $2
+		)`,
+		before, limit)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Exists воззвращает true, если пользователь с указанным id существует и не удалён, иначе false.
 func (r *UsersRepository) Exists(ctx context.Context, id int64) bool {
-	row := r.db.QueryRowContext(ctx, "SELECT id FROM users WHERE id = $1", id)
+	row := r.db.QueryRowContext(ctx, "SELECT id FROM users WHERE id = $1 AND deleted_at IS NULL", id)
 	return row.Scan() != sql.ErrNoRows
 }
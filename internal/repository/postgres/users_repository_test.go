@@ -2,8 +2,13 @@ package postgres
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"slices"
 
@@ -31,20 +36,31 @@ var mock = m{
 	nationality: "RU",
 }
 
+func (m m) user() model.User {
+	return model.User{
+		Name:        m.name,
+		Surname:     m.surname,
+		Patronymic:  m.patronymic,
+		Age:         m.age,
+		Gender:      m.gender,
+		Nationality: m.nationality,
+	}
+}
+
 func TestCreate(t *testing.T) {
 	loadEnv(t)
 	db := openDb(t)
 
 	repo := NewUsersRepository(db)
 
-	id, err := repo.Create(t.Context(), mock.name, mock.surname, mock.patronymic, mock.age, mock.gender, mock.nationality)
+	id, err := repo.Create(t.Context(), mock.user())
 	if err != nil {
 		t.Error(err)
 	}
 
 	// clear db
 	defer func() {
-		err = repo.Delete(t.Context(), id)
+		err = repo.Purge(t.Context(), id)
 		if err != nil {
 			t.Error(err)
 		}
@@ -72,7 +88,10 @@ func TestGetFiltered(t *testing.T) {
 	}
 
 	for i := range users {
-		id, err := repo.Create(t.Context(), users[i].Name, users[i].Surname, users[i].Patronymic, 23, "male or female", "RU")
+		u := users[i]
+		u.Age, u.Gender, u.Nationality = 23, "male or female", "RU"
+
+		id, err := repo.Create(t.Context(), u)
 		if err != nil {
 			t.Error(err)
 		}
@@ -82,14 +101,14 @@ func TestGetFiltered(t *testing.T) {
 	// clear db
 	defer func() {
 		for _, u := range users {
-			err := repo.Delete(t.Context(), u.Id)
+			err := repo.Purge(t.Context(), u.Id)
 			if err != nil {
 				t.Error(err)
 			}
 		}
 	}()
 
-	filtered, err := repo.GetFiltered(t.Context(), filter, 0, 100)
+	filtered, _, err := repo.GetFiltered(t.Context(), legacyFilter(t, filter), model.Page{Limit: 100})
 	if err != nil {
 		t.Error(err)
 	}
@@ -106,7 +125,7 @@ func TestUpdate(t *testing.T) {
 	db := openDb(t)
 
 	repo := NewUsersRepository(db)
-	id, err := repo.Create(t.Context(), mock.name, mock.surname, mock.patronymic, mock.age, mock.gender, mock.nationality)
+	id, err := repo.Create(t.Context(), mock.user())
 	if err != nil {
 		t.Error(err)
 	}
@@ -122,7 +141,7 @@ func TestUpdate(t *testing.T) {
 		t.Error(err)
 	}
 
-	user, err := repo.GetById(t.Context(), id, 0, 100000)
+	user, err := repo.GetById(t.Context(), id)
 	if err != nil {
 		t.Error(err)
 	}
@@ -132,6 +151,651 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
+func TestUpdateNull(t *testing.T) {
+	loadEnv(t)
+	db := openDb(t)
+
+	repo := NewUsersRepository(db)
+	id, err := repo.Create(t.Context(), mock.user())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// clear db
+	defer func() {
+		if err := repo.Purge(t.Context(), id); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	updates := map[string]any{"patronymic": nil, "age": nil, "gender": nil, "nationality": nil}
+	if err = repo.Update(t.Context(), id, updates); err != nil {
+		t.Fatal(err)
+	}
+
+	// пустые значения читаются как нулевые
+	user, err := repo.GetById(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Id != id || user.Patronymic != "" || user.Age != 0 || user.Gender != "" || user.Nationality != "" {
+		t.Errorf("unexpected user after clearing fields: %+v", user)
+	}
+}
+
+func TestGetFilteredByConfidence(t *testing.T) {
+	loadEnv(t)
+	db := openDb(t)
+
+	repo := NewUsersRepository(db)
+
+	confident := mock.user()
+	confident.GenderProbability, confident.GenderCount = 0.99, 5000
+	doubtful := mock.user()
+	doubtful.GenderProbability, doubtful.GenderCount = 0.51, 3
+
+	var ids []int64
+	for _, u := range []model.User{confident, doubtful} {
+		id, err := repo.Create(t.Context(), u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	// clear db
+	defer func() {
+		for _, id := range ids {
+			if err := repo.Purge(t.Context(), id); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	filter := map[string][]any{
+		"surname":                {mock.surname},
+		"min_gender_probability": {0.9},
+	}
+	filtered, _, err := repo.GetFiltered(t.Context(), legacyFilter(t, filter), model.Page{Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, u := range filtered {
+		if u.GenderProbability < 0.9 {
+			t.Errorf("user %d with gender probability %v passed the filter", u.Id, u.GenderProbability)
+		}
+		if u.Id == ids[0] {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("confident user %d didn't pass the filter", ids[0])
+	}
+}
+
+func TestGetFilteredOperators(t *testing.T) {
+	loadEnv(t)
+	db := openDb(t)
+
+	repo := NewUsersRepository(db)
+
+	users := []model.User{
+		{Name: "Иван", Surname: "Иванов", Age: 30, Nationality: "RU"},
+		{Name: "Пётр", Surname: "Ивлев", Age: 45, Nationality: "RU"},
+		{Name: "Мария", Surname: "Петрова", Patronymic: "Ивановна", Age: 35, Nationality: "BG"},
+	}
+	for i := range users {
+		id, err := repo.Create(t.Context(), users[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		users[i].Id = id
+	}
+
+	// clear db
+	defer func() {
+		for _, u := range users {
+			if err := repo.Purge(t.Context(), u.Id); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	ids := make([]any, len(users))
+	for i, u := range users {
+		ids[i] = float64(u.Id)
+	}
+	own := model.Filter{Field: "id", Op: model.OpIn, Value: ids}
+
+	tests := []struct {
+		filter model.Filter
+		want   []int64
+	}{
+		// возраст от 25 до 40 и фамилия начинается с «Ив»
+		{model.Filter{And: []model.Filter{
+			own,
+			{Field: "age", Op: model.OpGte, Value: 25},
+			{Field: "age", Op: model.OpLte, Value: 40},
+			{Field: "surname", Op: model.OpPrefix, Value: "Ив"},
+		}}, []int64{users[0].Id}},
+		// отчество не заполнено или национальность не RU
+		{model.Filter{And: []model.Filter{
+			own,
+			{Or: []model.Filter{
+				{Field: "patronymic", Op: model.OpIsNull},
+				{Not: &model.Filter{Field: "nationality", Op: model.OpEq, Value: "RU"}},
+			}},
+		}}, []int64{users[0].Id, users[1].Id, users[2].Id}},
+		{model.Filter{And: []model.Filter{
+			own,
+			{Field: "nationality", Op: model.OpNe, Value: "RU"},
+		}}, []int64{users[2].Id}},
+		// отчество не указано при создании
+		{model.Filter{And: []model.Filter{
+			own,
+			{Field: "patronymic", Op: model.OpIsNull, Value: true},
+		}}, []int64{users[0].Id, users[1].Id}},
+	}
+	for i, tt := range tests {
+		filtered, _, err := repo.GetFiltered(t.Context(), tt.filter, model.Page{Limit: 1000})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := make([]int64, 0, len(filtered))
+		for _, u := range filtered {
+			got = append(got, u.Id)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("filter %d: wanted %v, got %v", i, tt.want, got)
+		}
+	}
+}
+
+func TestGetFilteredKeyset(t *testing.T) {
+	loadEnv(t)
+	db := openDb(t)
+
+	repo := NewUsersRepository(db)
+
+	surname := fmt.Sprintf("Keyset%d", time.Now().UnixNano())
+	var ids []int64
+	create := func() {
+		id, err := repo.Create(t.Context(), model.User{Name: mock.name, Surname: surname})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	for range 5 {
+		create()
+	}
+
+	// clear db
+	defer func() {
+		for _, id := range ids {
+			if err := repo.Purge(t.Context(), id); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	filter := model.Filter{Field: "surname", Value: surname}
+	page := model.Page{Limit: 2}
+
+	var got []int64
+	for pages := 0; ; pages++ {
+		if pages > len(ids) {
+			t.Fatal("pagination doesn't stop")
+		}
+
+		users, next, err := repo.GetFiltered(t.Context(), filter, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, u := range users {
+			got = append(got, u.Id)
+		}
+
+		// пользователь, добавленный между страницами, не сдвигает выборку
+		if pages == 0 {
+			create()
+		}
+
+		if next == nil {
+			break
+		}
+		page.After = next
+	}
+
+	if !slices.Equal(got, ids) {
+		t.Errorf("wanted users %v, got %v", ids, got)
+	}
+
+	// у половины пользователей возраст и отчество пусты: страницы не повторяют их и заканчиваются
+	for i, id := range ids {
+		updates := map[string]any{"age": nil, "patronymic": nil}
+		if i%2 == 1 {
+			updates = map[string]any{"age": 20 + i%3, "patronymic": "Petrovich"}
+		}
+		if err := repo.Update(t.Context(), id, updates); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, s := range []string{"patronymic", "age", "age:desc", "patronymic:nulls_first", "age:desc:nulls_last"} {
+		sort, err := model.ParseSort(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		seen := make(map[int64]bool)
+		page := model.Page{Limit: 2, Sort: sort}
+		for pages := 0; ; pages++ {
+			if pages > len(ids) {
+				t.Fatalf("sort %s: pagination doesn't stop", s)
+			}
+
+			users, next, err := repo.GetFiltered(t.Context(), filter, page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, u := range users {
+				if seen[u.Id] {
+					t.Errorf("sort %s: user %d is returned twice", s, u.Id)
+				}
+				seen[u.Id] = true
+			}
+
+			if next == nil {
+				break
+			}
+			page.After = next
+		}
+		if len(seen) != len(ids) {
+			t.Errorf("sort %s: wanted %d users, got %d", s, len(ids), len(seen))
+		}
+	}
+}
+
+func TestGetFilteredSorted(t *testing.T) {
+	loadEnv(t)
+	db := openDb(t)
+
+	repo := NewUsersRepository(db)
+
+	surname := fmt.Sprintf("Sorted%d", time.Now().UnixNano())
+	users := []model.User{
+		{Name: "Anna", Surname: surname, Age: 30, Nationality: "RU"},
+		{Name: "Boris", Surname: surname, Age: 45, Nationality: "BG"},
+		{Name: "Clara", Surname: surname, Age: 30, Nationality: "BG"},
+		{Name: "Denis", Surname: surname, Age: 21, Nationality: "RU"},
+	}
+	for i := range users {
+		id, err := repo.Create(t.Context(), users[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		users[i].Id = id
+	}
+
+	// clear db
+	defer func() {
+		for _, u := range users {
+			if err := repo.Purge(t.Context(), u.Id); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	sort, err := model.ParseSort("nationality,age:desc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// страницы по курсору идут в том же порядке, что и вся выборка
+	var got []string
+	page := model.Page{Limit: 3, Sort: sort}
+	for {
+		filtered, next, err := repo.GetFiltered(t.Context(), model.Filter{Field: "surname", Value: surname}, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, u := range filtered {
+			got = append(got, u.Name)
+		}
+		if next == nil {
+			break
+		}
+		page.After = next
+	}
+
+	if want := []string{"Boris", "Clara", "Anna", "Denis"}; !slices.Equal(got, want) {
+		t.Errorf("wanted order %v, got %v", want, got)
+	}
+}
+
+func TestFacets(t *testing.T) {
+	loadEnv(t)
+	db := openDb(t)
+
+	repo := NewUsersRepository(db)
+
+	surname := fmt.Sprintf("Facets%d", time.Now().UnixNano())
+	users := []model.User{
+		{Name: "Anna", Surname: surname, Age: 30, Gender: "female", Nationality: "RU"},
+		{Name: "Boris", Surname: surname, Age: 45, Gender: "male", Nationality: "BG"},
+		{Name: "Clara", Surname: surname, Age: 17, Gender: "female", Nationality: "BG"},
+		{Name: "Denis", Surname: surname, Age: 70},
+	}
+	for i := range users {
+		id, err := repo.Create(t.Context(), users[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		users[i].Id = id
+	}
+
+	// clear db
+	defer func() {
+		for _, u := range users {
+			if err := repo.Purge(t.Context(), u.Id); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	filter := model.Filter{Field: "surname", Value: surname}
+	total, err := repo.CountFiltered(t.Context(), filter, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != len(users) {
+		t.Errorf("wanted total %d, got %d", len(users), total)
+	}
+
+	facets, err := repo.GetFacets(t.Context(), filter, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := model.Facets{
+		Gender:      map[string]int{"female": 2, "male": 1, model.FacetUnknown: 1},
+		Nationality: map[string]int{"RU": 1, "BG": 2, model.FacetUnknown: 1},
+		Age:         map[string]int{"0-17": 1, "25-34": 1, "45-54": 1, "65+": 1},
+	}
+	if !reflect.DeepEqual(facets, want) {
+		t.Errorf("wanted facets %v, got %v", want, facets)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	loadEnv(t)
+	db := openDb(t)
+
+	repo := NewUsersRepository(db)
+
+	// редкое имя, чтобы в базе не нашлись другие похожие пользователи
+	patronymic := fmt.Sprintf("Zyxw%d", time.Now().UnixNano())
+	users := []model.User{
+		{Name: "Мария", Surname: "Иванова", Patronymic: patronymic},
+		{Name: "Марина", Surname: "Иванова", Patronymic: patronymic},
+		{Name: "Анна", Surname: "Петрова", Patronymic: patronymic},
+	}
+	for i := range users {
+		id, err := repo.Create(t.Context(), users[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		users[i].Id = id
+	}
+
+	// clear db
+	defer func() {
+		for _, u := range users {
+			if err := repo.Purge(t.Context(), u.Id); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		{"Иванова Мар " + patronymic, []string{"Мария", "Марина"}},
+		{"Ivanova Mariya " + patronymic, []string{"Мария"}},
+		{"Петрва " + patronymic, []string{"Анна"}},
+	}
+	for _, tt := range tests {
+		results, err := repo.Search(t.Context(), tt.q, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, res := range results {
+			if res.Patronymic == patronymic {
+				got = append(got, res.Name)
+			}
+		}
+		if len(got) == 0 || got[0] != tt.want[0] {
+			t.Errorf("%q: wanted %v first, got %v", tt.q, tt.want[0], got)
+		}
+		for _, name := range tt.want {
+			if !slices.Contains(got, name) {
+				t.Errorf("%q: wanted %s in results, got %v", tt.q, name, got)
+			}
+		}
+	}
+
+	results, err := repo.Search(t.Context(), "Иванова Мар "+patronymic, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Highlight["surname"] != "<mark>Иванова</mark>" {
+		t.Errorf("wanted highlighted surname, got %v", results)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	loadEnv(t)
+	db := openDb(t)
+
+	repo := NewUsersRepository(db)
+
+	// редкое начало фамилии, чтобы в базе не нашлись другие подходящие пользователи
+	prefix := fmt.Sprintf("Zyxw%d", time.Now().UnixNano())
+	surnames := []string{prefix + "ов", prefix + "ов", prefix + "ова", prefix + "ов", prefix + "_ин"}
+	var ids []int64
+	for _, surname := range surnames {
+		id, err := repo.Create(t.Context(), model.User{Name: "Test", Surname: surname})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	// clear db
+	defer func() {
+		for _, id := range ids {
+			if err := repo.Purge(t.Context(), id); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	suggestions, err := repo.Suggest(t.Context(), "surname", strings.ToLower(prefix)+"о", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []model.Suggestion{{Value: prefix + "ов", Count: 3}, {Value: prefix + "ова", Count: 1}}
+	if !slices.Equal(suggestions, want) {
+		t.Errorf("wanted suggestions %v, got %v", want, suggestions)
+	}
+
+	// символы шаблонов LIKE в начале значения ищутся как есть
+	suggestions, err = repo.Suggest(t.Context(), "surname", prefix+"_", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []model.Suggestion{{Value: prefix + "_ин", Count: 1}}; !slices.Equal(suggestions, want) {
+		t.Errorf("wanted suggestions %v, got %v", want, suggestions)
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	loadEnv(t)
+	db := openDb(t)
+
+	repo := NewUsersRepository(db)
+
+	surname := fmt.Sprintf("Deleted%d", time.Now().UnixNano())
+	id, err := repo.Create(t.Context(), model.User{Name: "Anna", Surname: surname})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// clear db
+	defer func() {
+		if err := repo.Purge(t.Context(), id); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	if err = repo.Delete(t.Context(), id); err != nil {
+		t.Fatal(err)
+	}
+
+	filter := model.Filter{Field: "surname", Value: surname}
+	count := func(includeDeleted bool) int {
+		users, _, err := repo.GetFiltered(t.Context(), filter, model.Page{Limit: 10, IncludeDeleted: includeDeleted})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(users)
+	}
+	if n := count(false); n != 0 {
+		t.Errorf("deleted user is selected: got %d users", n)
+	}
+	if n := count(true); n != 1 {
+		t.Errorf("wanted deleted user with IncludeDeleted, got %d users", n)
+	}
+	if u, err := repo.GetById(t.Context(), id); err != nil || u.Id != 0 {
+		t.Errorf("wanted deleted user to be not found, got %v, %v", u, err)
+	}
+	if err = repo.Update(t.Context(), id, map[string]any{"name": "Olga"}); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("wanted ErrNotFound when updating deleted user, got %v", err)
+	}
+
+	// пользователь, удалённый недавно, не очищается
+	if _, err = repo.PurgeDeleted(t.Context(), time.Now().Add(-time.Hour), 100); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := repo.Restore(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !restored || count(false) != 1 {
+		t.Error("deleted user wasn't restored")
+	}
+	if restored, err = repo.Restore(t.Context(), id); err != nil || restored {
+		t.Errorf("user that isn't deleted was restored: %v, %v", restored, err)
+	}
+
+	// по истечении срока хранения удалённый пользователь очищается безвозвратно
+	if err = repo.Delete(t.Context(), id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = repo.PurgeDeleted(t.Context(), time.Now().Add(time.Hour), 100); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(true); n != 0 {
+		t.Errorf("deleted user wasn't purged: got %d users", n)
+	}
+}
+
+func TestNationalities(t *testing.T) {
+	loadEnv(t)
+	db := openDb(t)
+
+	repo := NewUsersRepository(db)
+
+	u := mock.user()
+	u.Nationality, u.NationalityProbability = "RU", 0.48
+	u.Nationalities = []model.Nationality{
+		{Country: "RU", Probability: 0.48},
+		{Country: "BG", Probability: 0.27},
+		{Country: "UA", Probability: 0.08},
+	}
+
+	id, err := repo.Create(t.Context(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Purge(t.Context(), id)
+
+	nationalities, err := repo.GetNationalities(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(nationalities, u.Nationalities) {
+		t.Errorf("wanted %v, got %v", u.Nationalities, nationalities)
+	}
+
+	// BG - второй кандидат, но его вероятность ниже порога только во втором фильтре
+	tests := []struct {
+		filter map[string][]any
+		found  bool
+	}{
+		{map[string][]any{"nationality_candidate": {"BG"}}, true},
+		{map[string][]any{"nationality_candidate": {"BG"}, "min_candidate_probability": {0.2}}, true},
+		{map[string][]any{"nationality_candidate": {"BG"}, "min_candidate_probability": {0.3}}, false},
+		{map[string][]any{"nationality_candidate": {"DE", "UA"}}, true},
+		{map[string][]any{"nationality_candidate": {"DE"}}, false},
+	}
+	for _, tt := range tests {
+		tt.filter["surname"] = []any{mock.surname}
+
+		users, _, err := repo.GetFiltered(t.Context(), legacyFilter(t, tt.filter), model.Page{Limit: 1000})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		found := slices.ContainsFunc(users, func(u model.User) bool { return u.Id == id })
+		if found != tt.found {
+			t.Errorf("filter %v: wanted found=%v, got %v", tt.filter, tt.found, found)
+		}
+	}
+
+	// повторное обогащение заменяет список кандидатов целиком
+	err = repo.SaveEnrichment(t.Context(), id, model.Enrichment{
+		Values:        map[string]any{"nationality": "BG"},
+		Nationalities: []model.Nationality{{Country: "BG", Probability: 0.6}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nationalities, _ = repo.GetNationalities(t.Context(), id); len(nationalities) != 1 || nationalities[0].Country != "BG" {
+		t.Errorf("candidates weren't replaced: %v", nationalities)
+	}
+
+	// национальность, заданная вручную, заменяет кандидатов обогатителей
+	if err = repo.Update(t.Context(), id, map[string]any{"nationality": "DE"}); err != nil {
+		t.Fatal(err)
+	}
+	for country, want := range map[string]bool{"BG": false, "DE": true} {
+		filter := map[string][]any{"surname": {mock.surname}, "nationality_candidate": {country}}
+		users, _, err := repo.GetFiltered(t.Context(), legacyFilter(t, filter), model.Page{Limit: 1000})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found := slices.ContainsFunc(users, func(u model.User) bool { return u.Id == id }); found != want {
+			t.Errorf("candidate %s after manual update: wanted found=%v, got %v", country, want, found)
+		}
+	}
+}
+
 // helpers
 
 func openDb(t *testing.T) *sql.DB {
@@ -152,3 +816,64 @@ func loadEnv(t *testing.T) {
 func contains(s []any, e any) bool {
 	return slices.Contains(s, e)
 }
+
+func TestProvenance(t *testing.T) {
+	loadEnv(t)
+	db := openDb(t)
+
+	repo := NewUsersRepository(db)
+	u := mock.user()
+	u.SetProvenance("age", model.Provenance{
+		Source:    model.SourceEnricher,
+		Provider:  "agify",
+		Raw:       `{"age":17}`,
+		UpdatedAt: time.Now(),
+	})
+
+	id, err := repo.Create(t.Context(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Purge(t.Context(), id)
+
+	// оператор исправляет возраст, и поле блокируется
+	err = repo.Update(t.Context(), id, map[string]any{"age": 62})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := repo.GetById(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := user.Provenance["age"]; p.Source != model.SourceOperator || p.Provider != "" || !p.Locked {
+		t.Errorf("unexpected age provenance %+v", p)
+	}
+
+	// результат обогащения не перезаписывает заблокированный возраст
+	err = repo.SaveEnrichment(t.Context(), id, model.Enrichment{
+		Values: map[string]any{"age": 30, "age_count": 100},
+		Provenance: map[string]model.Provenance{
+			"age": {Source: model.SourceEnricher, Provider: "agify", UpdatedAt: time.Now()},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, _ = repo.GetById(t.Context(), id); user.Age != 62 || user.AgeCount != 0 {
+		t.Errorf("locked age was overwritten: %d (count %d)", user.Age, user.AgeCount)
+	}
+
+	// после разблокировки обогащение снова управляет полем
+	n, err := repo.Unlock(t.Context(), id, "age")
+	if err != nil || n != 1 {
+		t.Fatalf("wanted 1 unlocked field, got %d (%v)", n, err)
+	}
+	err = repo.SaveEnrichment(t.Context(), id, model.Enrichment{Values: map[string]any{"age": 30}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, _ = repo.GetById(t.Context(), id); user.Age != 30 {
+		t.Errorf("unlocked age wasn't updated: %d", user.Age)
+	}
+}
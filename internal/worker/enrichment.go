@@ -0,0 +1,182 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/aachex/service/internal/enricher"
+	"github.com/aachex/service/internal/model"
+)
+
+type jobsRepository interface {
+	ProcessNext(
+		ctx context.Context,
+		handle func(ctx context.Context, job model.EnrichmentJob) error,
+		backoff func(attempt int) (time.Duration, bool),
+	) (bool, error)
+}
+
+type usersRepository interface {
+	GetById(ctx context.Context, id int64) (model.User, error)
+	SaveEnrichment(ctx context.Context, id int64, changes model.Enrichment) error
+}
+
+type enricherRegistry interface {
+	Select(names ...string) (enricher.Enricher, error)
+}
+
+// Config - настройки пула обработчиков заданий на обогащение.
+type Config struct {
+	Workers      int           // количество одновременно работающих обработчиков
+	PollInterval time.Duration // пауза между опросами очереди, когда в ней нет заданий
+	MaxAttempts  int           // максимальное количество попыток выполнить задание
+	BaseBackoff  time.Duration // задержка перед второй попыткой, удваивается с каждой следующей
+	MaxBackoff   time.Duration // максимальная задержка между попытками
+}
+
+// EnrichmentPool выполняет задания на обогащение пользователей из очереди в базе данных.
+type EnrichmentPool struct {
+	jobs      jobsRepository
+	users     usersRepository
+	enrichers enricherRegistry
+	logger    *slog.Logger
+	cfg       Config
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewEnrichmentPool(jr jobsRepository, ur usersRepository, er enricherRegistry, l *slog.Logger, cfg Config) *EnrichmentPool {
+	return &EnrichmentPool{
+		jobs:      jr,
+		users:     ur,
+		enrichers: er,
+		logger:    l,
+		cfg:       cfg,
+	}
+}
+
+// Start запускает обработчики. Они работают до вызова Shutdown.
+func (p *EnrichmentPool) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	for range max(p.cfg.Workers, 1) {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.run(ctx)
+		}()
+	}
+}
+
+// Shutdown останавливает обработчики и ждёт их завершения или отмены ctx.
+// Прерванные задания остаются в очереди и будут выполнены после перезапуска.
+func (p *EnrichmentPool) Shutdown(ctx context.Context) error {
+	if p.cancel == nil {
+		return nil
+	}
+	p.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *EnrichmentPool) run(ctx context.Context) {
+	for {
+		processed, err := p.jobs.ProcessNext(ctx, p.handle, p.backoff)
+		if err != nil && ctx.Err() == nil {
+			p.logger.Error("failed to process enrichment job", slog.String("error", err.Error()))
+		}
+
+		// если задание было, сразу берём следующее
+		if processed && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.cfg.PollInterval):
+		}
+	}
+}
+
+// handle обогащает пользователя из задания и сохраняет результат.
+func (p *EnrichmentPool) handle(ctx context.Context, job model.EnrichmentJob) error {
+	user, err := p.users.GetById(ctx, job.UserId)
+	if err != nil {
+		return err
+	}
+
+	// пользователь удалён, обогащать некого
+	if user.Id == 0 {
+		return nil
+	}
+
+	e, err := p.enrichers.Select(job.Enrichers...)
+	if err != nil {
+		return err
+	}
+
+	report := enricher.NewReport(e, e.Enrich(ctx, &user))
+
+	// сохраняем то, что удалось получить, даже если часть обогатителей завершилась с ошибкой.
+	// Время обогащения обновляется и при ошибке, чтобы RefreshSweeper не ставил задание повторно до RetryAfter.
+	changes := enricher.Changes(&user, e.Fields())
+	changes.Values["enriched_at"] = time.Now()
+	err = p.users.SaveEnrichment(ctx, user.Id, changes)
+	if errors.Is(err, model.ErrNotFound) {
+		// пользователя удалили во время обогащения
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// поля, о которых сервисы ничего не знают, повторно не запрашиваем
+	var errs []error
+	for field, o := range report {
+		if !o.Failed() {
+			continue
+		}
+		p.logger.Warn("enrichment job failed",
+			slog.Int64("jobId", job.Id),
+			slog.Int64("userId", job.UserId),
+			slog.Int("attempt", job.Attempts+1),
+			slog.String("field", field),
+			slog.String("provider", o.Provider),
+			slog.String("status", o.Status),
+			slog.String("reason", o.Reason))
+		errs = append(errs, fmt.Errorf("%s: %s", field, o.Reason))
+	}
+	return errors.Join(errs...)
+}
+
+// backoff возвращает экспоненциально растущую задержку перед следующей попыткой
+// и false, если попытки исчерпаны.
+func (p *EnrichmentPool) backoff(attempt int) (time.Duration, bool) {
+	if attempt >= p.cfg.MaxAttempts {
+		return 0, false
+	}
+
+	delay := p.cfg.BaseBackoff
+	for i := 1; i < attempt && delay < p.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, p.cfg.MaxBackoff), true
+}
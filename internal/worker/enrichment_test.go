@@ -0,0 +1,172 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aachex/service/internal/enricher"
+	"github.com/aachex/service/internal/model"
+)
+
+// fakeJobs - очередь заданий в памяти.
+type fakeJobs struct {
+	mu      sync.Mutex
+	pending []model.EnrichmentJob
+	done    []int64
+	failed  []int64
+	delays  []time.Duration
+}
+
+func (f *fakeJobs) ProcessNext(
+	ctx context.Context,
+	handle func(ctx context.Context, job model.EnrichmentJob) error,
+	backoff func(attempt int) (time.Duration, bool),
+) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.pending) == 0 {
+		return false, nil
+	}
+	job := f.pending[0]
+	f.pending = f.pending[1:]
+
+	err := handle(ctx, job)
+	job.Attempts++
+
+	delay, retry := backoff(job.Attempts)
+	switch {
+	case err == nil:
+		f.done = append(f.done, job.Id)
+	case retry:
+		f.delays = append(f.delays, delay)
+		f.pending = append(f.pending, job)
+	default:
+		f.failed = append(f.failed, job.Id)
+	}
+	return true, nil
+}
+
+type fakeUsers struct {
+	mu    sync.Mutex
+	users map[int64]model.User
+}
+
+func (f *fakeUsers) GetById(_ context.Context, id int64) (model.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.users[id], nil
+}
+
+func (f *fakeUsers) SaveEnrichment(_ context.Context, id int64, changes model.Enrichment) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	u := f.users[id]
+	if age, ok := changes.Values["age"]; ok {
+		u.Age = age.(int)
+	}
+	if at, ok := changes.Values["enriched_at"]; ok {
+		t := at.(time.Time)
+		u.EnrichedAt = &t
+	}
+	f.users[id] = u
+	return nil
+}
+
+// ageEnricher выставляет возраст 33 или возвращает ошибку, пока fails больше нуля.
+type ageEnricher struct {
+	mu    sync.Mutex
+	fails int
+}
+
+func (e *ageEnricher) Name() string     { return "age" }
+func (e *ageEnricher) Fields() []string { return []string{"age"} }
+
+func (e *ageEnricher) Enrich(_ context.Context, user *model.User) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.fails > 0 {
+		e.fails--
+		return errors.New("provider is down")
+	}
+	user.Age = 33
+	return nil
+}
+
+func TestEnrichmentPool(t *testing.T) {
+	jobs := &fakeJobs{pending: []model.EnrichmentJob{
+		{Id: 1, UserId: 10},
+		{Id: 2, UserId: 20},
+	}}
+	users := &fakeUsers{users: map[int64]model.User{
+		10: {Id: 10, Name: "Ivan"},
+		20: {Id: 20, Name: "Olga"},
+	}}
+	registry, err := enricher.NewRegistry(&ageEnricher{fails: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := NewEnrichmentPool(jobs, users, registry, slog.New(slog.NewTextHandler(io.Discard, nil)), Config{
+		Workers:      2,
+		PollInterval: time.Millisecond,
+		MaxAttempts:  3,
+		BaseBackoff:  time.Second,
+		MaxBackoff:   time.Minute,
+	})
+	pool.Start()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		jobs.mu.Lock()
+		done := len(jobs.done)
+		jobs.mu.Unlock()
+		if done == 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := pool.Shutdown(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(jobs.done) != 2 {
+		t.Fatalf("wanted 2 completed jobs, got %d", len(jobs.done))
+	}
+	if len(jobs.delays) != 1 || jobs.delays[0] != time.Second {
+		t.Errorf("wanted one retry after 1s, got %v", jobs.delays)
+	}
+	for id, u := range users.users {
+		if u.Age != 33 || u.EnrichedAt == nil {
+			t.Errorf("user %d wasn't enriched", id)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	pool := &EnrichmentPool{cfg: Config{
+		MaxAttempts: 5,
+		BaseBackoff: time.Second,
+		MaxBackoff:  5 * time.Second,
+	}}
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		delay, retry := pool.backoff(i + 1)
+		if !retry || delay != w {
+			t.Errorf("attempt %d: wanted %v, got %v (retry %t)", i+1, w, delay, retry)
+		}
+	}
+
+	if _, retry := pool.backoff(5); retry {
+		t.Error("wanted no retry after the last attempt")
+	}
+}
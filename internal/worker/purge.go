@@ -0,0 +1,109 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type deletedUsersRepository interface {
+	PurgeDeleted(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+// PurgeConfig - настройки очистки удалённых пользователей.
+type PurgeConfig struct {
+	Interval  time.Duration // пауза между проходами
+	Retention time.Duration // сколько удалённый пользователь хранится, прежде чем будет очищен
+	Batch     int           // максимальное количество пользователей, которое очищается за один запрос
+}
+
+// PurgeSweeper периодически безвозвратно удаляет пользователей, удалённых раньше, чем Retention назад.
+// За проход очищаются все такие пользователи, но не больше Batch за один запрос, чтобы не держать долгих блокировок.
+type PurgeSweeper struct {
+	users  deletedUsersRepository
+	logger *slog.Logger
+	cfg    PurgeConfig
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	now func() time.Time
+}
+
+func NewPurgeSweeper(ur deletedUsersRepository, l *slog.Logger, cfg PurgeConfig) *PurgeSweeper {
+	return &PurgeSweeper{
+		users:  ur,
+		logger: l,
+		cfg:    cfg,
+		now:    time.Now,
+	}
+}
+
+// Start запускает проходы. Они выполняются до вызова Shutdown.
+func (s *PurgeSweeper) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			s.sweep(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Shutdown останавливает проходы и ждёт завершения текущего или отмены ctx.
+func (s *PurgeSweeper) Shutdown(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sweep очищает пользователей, срок хранения которых истёк.
+func (s *PurgeSweeper) sweep(ctx context.Context) {
+	before := s.now().Add(-s.cfg.Retention)
+
+	var total int64
+	for {
+		n, err := s.users.PurgeDeleted(ctx, before, s.cfg.Batch)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.logger.Error("failed to purge deleted users", slog.String("error", err.Error()))
+			}
+			break
+		}
+		total += n
+		if n == 0 || n < int64(s.cfg.Batch) || ctx.Err() != nil {
+			break
+		}
+	}
+	if total > 0 {
+		s.logger.Info("deleted users purged", slog.Int64("count", total))
+	}
+}
@@ -0,0 +1,72 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// fakeDeleted хранит время удаления пользователей и очищает их, как PurgeDeleted.
+type fakeDeleted struct {
+	deletedAt []time.Time
+	calls     int
+	err       error
+}
+
+func (f *fakeDeleted) PurgeDeleted(_ context.Context, before time.Time, limit int) (int64, error) {
+	f.calls++
+	if f.err != nil {
+		return 0, f.err
+	}
+
+	var kept []time.Time
+	var n int64
+	for _, t := range f.deletedAt {
+		if t.Before(before) && n < int64(limit) {
+			n++
+			continue
+		}
+		kept = append(kept, t)
+	}
+	f.deletedAt = kept
+	return n, nil
+}
+
+func TestPurgeRetention(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	now := time.Now()
+
+	users := &fakeDeleted{}
+	for i := range 5 {
+		users.deletedAt = append(users.deletedAt, now.Add(-time.Duration(40+i)*24*time.Hour))
+	}
+	recent := now.Add(-24 * time.Hour)
+	users.deletedAt = append(users.deletedAt, recent)
+
+	s := NewPurgeSweeper(users, logger, PurgeConfig{Retention: 30 * 24 * time.Hour, Batch: 2})
+	s.now = func() time.Time { return now }
+	s.sweep(t.Context())
+
+	// устаревшие пользователи очищаются за несколько запросов, недавно удалённый остаётся
+	if len(users.deletedAt) != 1 || !users.deletedAt[0].Equal(recent) {
+		t.Errorf("wanted only recently deleted user to remain, got %v", users.deletedAt)
+	}
+	if users.calls != 3 {
+		t.Errorf("wanted 3 batches, got %d", users.calls)
+	}
+}
+
+func TestPurgeError(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	users := &fakeDeleted{err: errors.New("db is down")}
+	s := NewPurgeSweeper(users, logger, PurgeConfig{Retention: time.Hour, Batch: 10})
+	s.sweep(t.Context())
+
+	if users.calls != 1 {
+		t.Errorf("wanted sweep to stop after an error, got %d calls", users.calls)
+	}
+}
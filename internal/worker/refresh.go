@@ -0,0 +1,129 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/aachex/service/internal/enricher"
+)
+
+type staleUsersRepository interface {
+	EnqueueStale(ctx context.Context, maxAge, retryAfter time.Duration, limit int) (int64, error)
+}
+
+// RefreshConfig - настройки фонового повторного обогащения.
+type RefreshConfig struct {
+	Interval     time.Duration // пауза между проходами
+	Batch        int           // максимальное количество заданий, которое ставится за один проход
+	MaxAge       time.Duration // через сколько обогащённый пользователь считается устаревшим
+	RetryAfter   time.Duration // через сколько повторять обогащение пользователя с незаполненными полями
+	QuotaReserve int           // часть суточной квоты провайдеров, которая оставляется для новых пользователей
+}
+
+// RefreshSweeper периодически ставит в очередь задания на повторное обогащение устаревших пользователей.
+// Сами задания выполняет EnrichmentPool.
+//
+// Чтобы не выйти за квоты провайдеров, за проход ставится не больше Batch заданий и не больше,
+// чем осталось запросов у провайдера с наименьшим остатком квоты за вычетом QuotaReserve.
+// Пока автомат защиты какого-либо провайдера разомкнут, проходы пропускаются.
+type RefreshSweeper struct {
+	users    staleUsersRepository
+	breakers []*enricher.Breaker
+	logger   *slog.Logger
+	cfg      RefreshConfig
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewRefreshSweeper(ur staleUsersRepository, breakers []*enricher.Breaker, l *slog.Logger, cfg RefreshConfig) *RefreshSweeper {
+	return &RefreshSweeper{
+		users:    ur,
+		breakers: breakers,
+		logger:   l,
+		cfg:      cfg,
+	}
+}
+
+// Start запускает проходы. Они выполняются до вызова Shutdown.
+func (s *RefreshSweeper) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			s.sweep(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Shutdown останавливает проходы и ждёт завершения текущего или отмены ctx.
+func (s *RefreshSweeper) Shutdown(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sweep ставит в очередь задания на обогащение устаревших пользователей с учётом квот провайдеров.
+func (s *RefreshSweeper) sweep(ctx context.Context) {
+	limit := s.limit()
+	if limit <= 0 {
+		s.logger.Debug("refresh skipped: providers are unavailable or out of quota")
+		return
+	}
+
+	n, err := s.users.EnqueueStale(ctx, s.cfg.MaxAge, s.cfg.RetryAfter, limit)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Error("failed to enqueue stale users", slog.String("error", err.Error()))
+		}
+		return
+	}
+	if n > 0 {
+		s.logger.Info("stale users enqueued for enrichment", slog.Int64("count", n))
+	}
+}
+
+// limit возвращает, сколько заданий можно поставить за проход.
+func (s *RefreshSweeper) limit() int {
+	limit := s.cfg.Batch
+	for _, b := range s.breakers {
+		status := b.Status()
+		if status.State == enricher.BreakerOpen {
+			return 0
+		}
+		// квота после ResetAt уже обновилась, хотя автомат узнает об этом только при следующем запросе
+		if status.Quota != nil && time.Now().Before(status.Quota.ResetAt) {
+			limit = min(limit, status.Quota.Remaining-s.cfg.QuotaReserve)
+		}
+	}
+	return limit
+}
@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/aachex/service/internal/enricher"
+)
+
+// fakeStale запоминает лимиты, с которыми ставились задания.
+type fakeStale struct {
+	limits []int
+}
+
+func (f *fakeStale) EnqueueStale(_ context.Context, _, _ time.Duration, limit int) (int64, error) {
+	f.limits = append(f.limits, limit)
+	return int64(limit), nil
+}
+
+func TestRefreshThrottling(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := RefreshConfig{Batch: 20, QuotaReserve: 5}
+
+	agify := enricher.NewBreaker("agify", 0, enricher.BreakerConfig{Threshold: 1, Cooldown: time.Minute})
+	genderize := enricher.NewBreaker("genderize", 0, enricher.BreakerConfig{Threshold: 1, Cooldown: time.Minute})
+
+	users := &fakeStale{}
+	s := NewRefreshSweeper(users, []*enricher.Breaker{agify, genderize}, logger, cfg)
+
+	// квоты неизвестны, ограничивает только Batch
+	s.sweep(t.Context())
+
+	// у genderize осталось 12 запросов, 5 из них оставляем для новых пользователей
+	genderize.Success(t.Context(), &enricher.Quota{Limit: 100, Remaining: 12, ResetAt: time.Now().Add(time.Hour)})
+	s.sweep(t.Context())
+
+	// agify отключён, проход пропускается
+	agify.Failure(t.Context(), errors.New("provider is down"), nil)
+	s.sweep(t.Context())
+
+	if len(users.limits) != 2 || users.limits[0] != 20 || users.limits[1] != 7 {
+		t.Errorf("unexpected limits %v", users.limits)
+	}
+}
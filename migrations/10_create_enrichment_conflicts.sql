@@ -0,0 +1,11 @@
+CREATE TABLE enrichment_conflicts(
+    id BIGSERIAL PRIMARY KEY NOT NULL,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    field TEXT NOT NULL,
+    strategy TEXT NOT NULL,
+    chosen TEXT NOT NULL,
+    candidates JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX enrichment_conflicts_created_at_idx ON enrichment_conflicts(created_at DESC);
\ No newline at end of file
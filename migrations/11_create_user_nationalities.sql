@@ -0,0 +1,9 @@
+CREATE TABLE user_nationalities(
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    country_id TEXT NOT NULL,
+    probability DOUBLE PRECISION NOT NULL,
+    rank INT NOT NULL,
+    PRIMARY KEY (user_id, country_id)
+);
+
+CREATE INDEX user_nationalities_country_idx ON user_nationalities(country_id, probability);
\ No newline at end of file
@@ -0,0 +1,20 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- users_translit переводит текст в латиницу в нижнем регистре так же, как translit в internal/repository/postgres/search.go.
+-- Кириллица сначала приводится к нижнему регистру через translate: lower зависит от локали базы.
+CREATE FUNCTION users_translit(s TEXT) RETURNS TEXT
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+    SELECT translate(
+        replace(replace(replace(replace(replace(replace(replace(replace(replace(
+            lower(translate(s, 'АБВГДЕЁЖЗИЙКЛМНОПРСТУФХЦЧШЩЪЫЬЭЮЯ', 'абвгдеёжзийклмнопрстуфхцчшщъыьэюя')),
+            'щ', 'shch'), 'ж', 'zh'), 'х', 'kh'), 'ц', 'ts'), 'ч', 'ch'), 'ш', 'sh'), 'ю', 'yu'), 'я', 'ya'), 'ё', 'e'),
+        'абвгдезийклмнопрстуфыэwjъь', 'abvgdeziyklmnoprstufyevy')
+$$;
+
+ALTER TABLE users
+ADD COLUMN search_name TEXT GENERATED ALWAYS AS (
+    users_translit(surname || ' ' || name || ' ' || coalesce(patronymic, ''))
+) STORED;
+
+CREATE INDEX users_search_name_trgm_idx ON users USING GIN (search_name gin_trgm_ops);
+CREATE INDEX users_search_name_fts_idx ON users USING GIN (to_tsvector('simple', search_name));
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- индексы для подсказок по началу имени, фамилии и отчества без учёта регистра
+CREATE INDEX users_name_prefix_idx ON users(lower(name) text_pattern_ops);
+CREATE INDEX users_surname_prefix_idx ON users(lower(surname) text_pattern_ops);
+CREATE INDEX users_patronymic_prefix_idx ON users(lower(patronymic) text_pattern_ops);
\ No newline at end of file
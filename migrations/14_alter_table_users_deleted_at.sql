@@ -0,0 +1,5 @@
+ALTER TABLE users
+ADD COLUMN deleted_at TIMESTAMPTZ;
+
+-- удалённые пользователи выбираются только для очистки, остальные запросы идут по неудалённым
+CREATE INDEX users_deleted_at_idx ON users(deleted_at) WHERE deleted_at IS NOT NULL;
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- незаполненные необязательные поля хранятся как NULL, а не как пустая строка или ноль
+UPDATE users
+SET patronymic = NULLIF(patronymic, ''),
+    age = NULLIF(age, 0),
+    gender = NULLIF(gender, ''),
+    nationality = NULLIF(nationality, '')
+WHERE patronymic = '' OR age = 0 OR gender = '' OR nationality = '';
\ No newline at end of file
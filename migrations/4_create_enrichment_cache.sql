@@ -0,0 +1,5 @@
+CREATE TABLE enrichment_cache(
+    key TEXT PRIMARY KEY NOT NULL,
+    value BYTEA NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
\ No newline at end of file
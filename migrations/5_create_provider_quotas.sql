@@ -0,0 +1,7 @@
+CREATE TABLE provider_quotas(
+    provider TEXT PRIMARY KEY NOT NULL,
+    quota_limit INTEGER NOT NULL,
+    remaining INTEGER NOT NULL,
+    reset_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
\ No newline at end of file
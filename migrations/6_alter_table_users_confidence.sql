@@ -0,0 +1,6 @@
+ALTER TABLE users
+ADD COLUMN age_count INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN gender_probability DOUBLE PRECISION NOT NULL DEFAULT 0,
+ADD COLUMN gender_count INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN nationality_probability DOUBLE PRECISION NOT NULL DEFAULT 0,
+ADD COLUMN nationality_count INTEGER NOT NULL DEFAULT 0;
\ No newline at end of file
@@ -0,0 +1,8 @@
+ALTER TABLE users
+ADD COLUMN enriched_at TIMESTAMPTZ;
+
+-- пользователи, у которых уже заполнены все поля, считаются обогащёнными в момент миграции
+UPDATE users SET enriched_at = now()
+WHERE age IS NOT NULL AND age <> 0 AND gender IS NOT NULL AND gender <> '' AND nationality IS NOT NULL AND nationality <> '';
+
+CREATE INDEX users_enriched_at_idx ON users(enriched_at NULLS FIRST);
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE user_provenance(
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    field TEXT NOT NULL,
+    source TEXT NOT NULL,
+    provider TEXT NOT NULL DEFAULT '',
+    raw TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, field)
+);
\ No newline at end of file
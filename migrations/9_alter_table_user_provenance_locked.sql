@@ -0,0 +1,5 @@
+ALTER TABLE user_provenance
+ADD COLUMN locked BOOLEAN NOT NULL DEFAULT false;
+
+-- значения, заданные вручную, закрыты от перезаписи обогатителями
+UPDATE user_provenance SET locked = true WHERE source IN ('operator', 'import');
\ No newline at end of file